@@ -0,0 +1,150 @@
+// Package schema defines the inputs.json file the experiment runner passes to
+// the demo applications, and the calibration they report back. Both sides
+// decode them through this package, so a field can't be renamed on one side
+// only.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Version of the schema. Bump it whenever a field is added, removed or changes
+// its meaning, so that a runner and an application built from different
+// revisions refuse to talk to each other instead of silently disagreeing.
+const Version = 2
+
+// Input holds the workload parameters of a demo application.
+type Input struct {
+	// Version is the schema version the file was written with.
+	Version int `json:"version"`
+
+	// Workers is the number of workers to use. The exact meaning of this
+	// depends on the language. For example, in Go it's GOMAXPROCS. In node it's
+	// the number of cluster processes to spawn. The value of workers defaults
+	// to the number of logical CPUs on the machine.
+	Workers int `json:"workers"`
+
+	// LoopsCPU (in seconds) is the amount of CPU time to spend in a tight for
+	// loop in the request handler for each request. If set, the value of Loops
+	// will be ignored and overwritten with an auto-calibrated value.
+	LoopsCPU float64 `json:"loops_cpu"`
+
+	// LoopsNum is the number of iterations to perform in a tight loop.
+	LoopsNum int `json:"loops_num"`
+
+	// AllocsCPU (in seconds) is the amount of CPU time to spend in a for loop
+	// doing allocations of allocs_size bytes in the request handler for each
+	// request. The allocations are kept alive for the duration of the request.
+	// If set, the value of AllocsNum will be ignored and overwritten with an
+	// auto-calibrated value.
+	AllocsCPU float64 `json:"allocs_cpu"`
+
+	// AllocsNum is the number of allocations to perform in a tight loop.
+	AllocsNum int `json:"allocs_num"`
+
+	// AllocsSize is the size of the allocations to perform in a tight loop.
+	AllocsSize int `json:"allocs_size"`
+
+	// Exceptions controls whether to throw an exception or not in the request
+	// handler. The handler then responds with a 500.
+	Exceptions bool `json:"exceptions"`
+
+	// Panic makes the request handler throw exceptions by panicking at the
+	// bottom of its call stack and recovering in the handler, instead of
+	// returning an error.
+	Panic bool `json:"panic"`
+
+	// OffCPU time (in seconds) to spend in the request handler for each request.
+	OffCPU float64 `json:"off_cpu"`
+
+	// Recursive refers to the Stack: should it be unique functions or a recursive function call.
+	Recursive bool `json:"recursive"`
+
+	// StackDepth is the number of additional frames the request handler does
+	// its work below, see Recursive.
+	StackDepth int `json:"stack_depth"`
+
+	// Port to listen on for the application.
+	Port int `json:"port"`
+
+	// OTelEndpoint is the OpenTelemetry collector endpoint (e.g. "otel-collector:4318")
+	OTelEndpoint string `json:"otel_endpoint"`
+
+	// Profiling serves the net/http/pprof endpoints under /debug/pprof/ and
+	// enables the mutex and block profiles, which cost some overhead.
+	Profiling bool `json:"profiling"`
+}
+
+// Calibration is what the /calibrate endpoint of a demo application returns:
+// the iteration counts its handlers use after calibrating LoopsCPU and
+// AllocsCPU.
+type Calibration struct {
+	LoopsNum  int `json:"loops_num"`
+	AllocsNum int `json:"allocs_num"`
+}
+
+// Validate checks that the inputs are complete and consistent.
+func (in *Input) Validate() error {
+	var errs []error
+	if in.Version != Version {
+		errs = append(errs, fmt.Errorf("schema version %d is not supported (want %d)", in.Version, Version))
+	}
+	if in.Port < 1 || in.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", in.Port))
+	}
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{
+		{"workers", float64(in.Workers)},
+		{"loops_cpu", in.LoopsCPU},
+		{"loops_num", float64(in.LoopsNum)},
+		{"allocs_cpu", in.AllocsCPU},
+		{"allocs_num", float64(in.AllocsNum)},
+		{"allocs_size", float64(in.AllocsSize)},
+		{"off_cpu", in.OffCPU},
+		{"stack_depth", float64(in.StackDepth)},
+	} {
+		if f.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", f.name))
+		}
+	}
+	if (in.AllocsCPU > 0 || in.AllocsNum > 0) && in.AllocsSize <= 0 {
+		errs = append(errs, errors.New("allocs_size must be positive when allocating"))
+	}
+	if in.Panic && !in.Exceptions {
+		errs = append(errs, errors.New("panic requires exceptions"))
+	}
+	return errors.Join(errs...)
+}
+
+// Decode parses and validates inputs. Unknown fields are rejected.
+func Decode(data []byte) (*Input, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var in Input
+	if err := dec.Decode(&in); err != nil {
+		return nil, err
+	}
+	if err := in.Validate(); err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// Load reads, parses and validates the inputs file at path.
+func Load(path string) (*Input, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	in, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return in, nil
+}
@@ -0,0 +1,36 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `{"version": 2, "port": 8080, "allocs_cpu": 0.001, "allocs_size": 64}`, ""},
+		{"unknown field", `{"version": 2, "port": 8080, "alloc_size": 64}`, "unknown field"},
+		{"old version", `{"version": 0, "port": 8080}`, "schema version"},
+		{"missing port", `{"version": 2}`, "port"},
+		{"negative", `{"version": 2, "port": 8080, "stack_depth": -1}`, "stack_depth"},
+		{"allocs without size", `{"version": 2, "port": 8080, "allocs_num": 10}`, "allocs_size"},
+		{"panic without exceptions", `{"version": 2, "port": 8080, "panic": true}`, "panic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package workload
+
+// callStack calls fn below StackDepth additional frames, either of a single
+// recursive function or of a chain of distinct functions.
+func (a *App) callStack(fn func()) {
+	if a.Recursive {
+		recurse(a.StackDepth, fn)
+	} else {
+		stack0(a.StackDepth, fn)
+	}
+}
+
+//go:noinline
+func recurse(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	recurse(depth-1, fn)
+}
+
+// stack0 to stack9 form the chain of distinct functions used for
+// non-recursive call stacks. Deeper stacks wrap around from stack9 to stack0.
+
+//go:noinline
+func stack0(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack1(depth-1, fn)
+}
+
+//go:noinline
+func stack1(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack2(depth-1, fn)
+}
+
+//go:noinline
+func stack2(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack3(depth-1, fn)
+}
+
+//go:noinline
+func stack3(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack4(depth-1, fn)
+}
+
+//go:noinline
+func stack4(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack5(depth-1, fn)
+}
+
+//go:noinline
+func stack5(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack6(depth-1, fn)
+}
+
+//go:noinline
+func stack6(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack7(depth-1, fn)
+}
+
+//go:noinline
+func stack7(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack8(depth-1, fn)
+}
+
+//go:noinline
+func stack8(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack9(depth-1, fn)
+}
+
+//go:noinline
+func stack9(depth int, fn func()) {
+	if depth <= 0 {
+		fn()
+		return
+	}
+	stack0(depth-1, fn)
+}
@@ -0,0 +1,108 @@
+package workload
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// errLoad is the error the load handler fails with if Exceptions is set.
+var errLoad = errors.New("load failed")
+
+// work performs the per-request work at the bottom of a call stack
+// StackDepth frames deep. If Exceptions is set it fails with errLoad, by
+// panicking if Panic is set so that the panic unwinds the whole stack.
+func (a *App) work() error {
+	var err error
+	a.callStack(func() {
+		allocs := allocsLoop(a.AllocsNum, a.AllocsSize)
+		simulateOffCPU(a.OffCPU)
+		cpuLoop(a.LoopsNum)
+		runtime.KeepAlive(allocs)
+		if a.Exceptions {
+			if a.Panic {
+				panic(errLoad)
+			}
+			err = errLoad
+		}
+	})
+	return err
+}
+
+// cpuLoop performs a computationally expensive loop that scales with iterations
+// The function uses volatile arithmetic operations that are unlikely to be
+// optimized away.
+func cpuLoop(iterations int) {
+	// Start with some non-zero values to prevent optimization
+	result := int64(0x1234)
+	// Use a volatile prime number to avoid simple pattern recognition
+	volatile := int64(982451653)
+	for i := range iterations {
+		// Mix of operations to prevent easy compiler optimizations
+		result = ((result * 48271) % 2147483647) ^ volatile
+		volatile = (volatile*37 + result) % 9973
+		// XOR with loop counter to ensure the result depends on the loop iteration
+		result ^= int64(i)
+	}
+}
+
+//go:noinline
+func allocsLoop(iterations int, allocSize int) allocs {
+	a := allocs{slices: make([][]byte, 0, iterations)}
+	for range iterations {
+		a.slices = append(a.slices, make([]byte, allocSize))
+	}
+	return a
+}
+
+type allocs struct {
+	slices [][]byte
+}
+
+// allocSink keeps allocsChurn's allocations from being optimized away.
+var allocSink []byte
+
+// allocsChurn allocates like allocsLoop but drops each allocation right away,
+// so that calibrating the cost of allocating doesn't retain hundreds of MB.
+//
+//go:noinline
+func allocsChurn(iterations int, allocSize int) {
+	for range iterations {
+		allocSink = make([]byte, allocSize)
+	}
+	allocSink = nil
+}
+
+func simulateOffCPU(seconds float64) {
+	if seconds <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(seconds * float64(time.Second)))
+}
+
+// calibrate overwrites LoopsNum and AllocsNum with the number of iterations
+// that take LoopsCPU and AllocsCPU seconds on this machine, if they are set.
+func (a *App) calibrate() {
+	if a.LoopsCPU > 0 {
+		a.LoopsNum = calibrateIterations(a.LoopsCPU, cpuLoop)
+	}
+	if a.AllocsCPU > 0 {
+		a.AllocsNum = calibrateIterations(a.AllocsCPU, func(n int) {
+			allocsChurn(n, a.AllocsSize)
+		})
+	}
+}
+
+// calibrateIterations doubles the number of iterations passed to fn until a
+// single call takes long enough to be measured reliably, and then scales the
+// count to the requested number of seconds.
+func calibrateIterations(seconds float64, fn func(iterations int)) int {
+	const minElapsed = 200 * time.Millisecond
+	for n := 1000; ; n *= 2 {
+		start := time.Now()
+		fn(n)
+		if elapsed := time.Since(start); elapsed >= minElapsed {
+			return max(1, int(float64(n)*seconds/elapsed.Seconds()))
+		}
+	}
+}
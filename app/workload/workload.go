@@ -0,0 +1,201 @@
+// Package workload implements the demo application shared by all scenarios:
+// the handlers, the per-request work they do and the server lifecycle.
+// Scenarios that instrument the application from within plug into it through
+// Hooks, so that every scenario measures the identical workload.
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"fosdem2026/app/schema"
+)
+
+// Hooks let a scenario instrument the application. All hooks are optional.
+type Hooks struct {
+	// Middleware wraps the handler serving all endpoints, e.g. to trace
+	// every request.
+	Middleware func(http.Handler) http.Handler
+
+	// Start is called when the handler with the given name ("health" or
+	// "load") starts serving a request. The function it returns, if not nil,
+	// is called once the request is served, with the error it failed with.
+	Start func(handler string, r *http.Request) (end func(err error))
+}
+
+// App is a demo application running the workload described by its inputs.
+type App struct {
+	schema.Input
+	Hooks Hooks
+}
+
+// Load reads the inputs file at path and returns an application running the
+// workload it describes.
+func Load(path string) (*App, error) {
+	in, err := schema.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &App{Input: *in}, nil
+}
+
+const (
+	// mutexProfileFraction and blockProfileRate are the sampling rates of the
+	// mutex and block profiles with Profiling: one in 10 contention events,
+	// and one blocking event per 10µs spent blocked.
+	mutexProfileFraction = 10
+	blockProfileRate     = 10000
+)
+
+// Init applies Workers and Profiling and calibrates the per-request work. It must be called
+// before serving requests.
+func (a *App) Init() {
+	if a.Workers != 0 {
+		log.Printf("Setting GOMAXPROCS to %d", a.Workers)
+		runtime.GOMAXPROCS(a.Workers)
+	}
+	if a.Profiling {
+		runtime.SetMutexProfileFraction(mutexProfileFraction)
+		runtime.SetBlockProfileRate(blockProfileRate)
+	}
+
+	// Calibrate after GOMAXPROCS is set so the measurement matches the
+	// conditions the handlers will run under.
+	a.calibrate()
+	log.Printf("Calibrated loops_num=%d allocs_num=%d", a.LoopsNum, a.AllocsNum)
+}
+
+// Handler returns the handler serving all endpoints, wrapped in the
+// Middleware hook. overrides replaces the handlers of the given paths, for
+// scenarios that need them to be distinct functions of their own. With
+// Profiling, it also serves the pprof endpoints, outside of the Middleware
+// hook so that fetching profiles doesn't add to the telemetry under test.
+func (a *App) Handler(overrides map[string]http.HandlerFunc) http.Handler {
+	routes := map[string]http.HandlerFunc{
+		"/health": func(w http.ResponseWriter, r *http.Request) {
+			end := a.start("health", r)
+			HealthHandler(w, r)
+			end(nil)
+		},
+		"/load":      a.LoadHandler,
+		"/calibrate": a.CalibrateHandler,
+	}
+	for path, h := range overrides {
+		routes[path] = h
+	}
+	mux := http.NewServeMux()
+	for path, h := range routes {
+		mux.HandleFunc(path, h)
+	}
+	var handler http.Handler = mux
+	if a.Hooks.Middleware != nil {
+		handler = a.Hooks.Middleware(mux)
+	}
+	if !a.Profiling {
+		return handler
+	}
+	outer := http.NewServeMux()
+	outer.HandleFunc("/debug/pprof/", pprof.Index)
+	outer.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	outer.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	outer.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	outer.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	outer.Handle("/", handler)
+	return outer
+}
+
+func (a *App) start(handler string, r *http.Request) func(error) {
+	if a.Hooks.Start != nil {
+		if end := a.Hooks.Start(handler, r); end != nil {
+			return end
+		}
+	}
+	return func(error) {}
+}
+
+// HealthHandler reports that the application is up.
+func HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	_, _ = io.WriteString(w, "OK\n")
+}
+
+// LoadHandler does the per-request work and responds with "Hello World", or
+// with a 500 if the work fails.
+func (a *App) LoadHandler(w http.ResponseWriter, r *http.Request) {
+	end := a.start("load", r)
+	err := a.load(w)
+	end(err)
+}
+
+// load does the work and writes the response. A panic in the work is
+// recovered into a 500 response, like a typical recovery middleware would.
+func (a *App) load(w http.ResponseWriter) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			var ok bool
+			if err, ok = p.(error); !ok {
+				err = fmt.Errorf("%v", p)
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}()
+	if err := a.work(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	_, _ = io.WriteString(w, "Hello World\n")
+	return nil
+}
+
+// CalibrateHandler reports the calibrated iteration counts to the runner.
+func (a *App) CalibrateHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(schema.Calibration{LoopsNum: a.LoopsNum, AllocsNum: a.AllocsNum})
+}
+
+// Serve serves handler on the given port until the process receives SIGINT
+// or SIGTERM, and then shuts the server down gracefully.
+func Serve(port int, handler http.Handler) error {
+	addr := fmt.Sprintf(":%d", port)
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	// Channel to listen for interrupt signal to gracefully shutdown the server
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	// Run the server in a goroutine
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s...", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	// Wait for interrupt signal
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server error: %w", err)
+	case sig := <-stop:
+		log.Printf("Received signal %d (%s), shutting down...", sig, sig.String())
+	}
+
+	// Shutdown the server gracefully
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("server forced to shutdown: %w", err)
+	}
+	log.Println("Server exiting")
+	return nil
+}
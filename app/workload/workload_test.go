@@ -0,0 +1,89 @@
+package workload
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fosdem2026/app/schema"
+)
+
+func TestLoadHandlerHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      schema.Input
+		wantStatus int
+		wantErr    error
+	}{
+		{"ok", schema.Input{StackDepth: 3}, http.StatusOK, nil},
+		{"error", schema.Input{Exceptions: true}, http.StatusInternalServerError, errLoad},
+		{"panic", schema.Input{Exceptions: true, Panic: true, StackDepth: 12}, http.StatusInternalServerError, errLoad},
+		{"recursive panic", schema.Input{Exceptions: true, Panic: true, Recursive: true, StackDepth: 12}, http.StatusInternalServerError, errLoad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handlers []string
+			var gotErr error
+			wrapped := false
+			app := &App{Input: tt.input, Hooks: Hooks{
+				Middleware: func(h http.Handler) http.Handler {
+					return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						wrapped = true
+						h.ServeHTTP(w, r)
+					})
+				},
+				Start: func(handler string, _ *http.Request) func(error) {
+					handlers = append(handlers, handler)
+					return func(err error) { gotErr = err }
+				},
+			}}
+
+			rec := httptest.NewRecorder()
+			app.Handler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/load", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("end hook got error %v, want %v", gotErr, tt.wantErr)
+			}
+			if !wrapped || len(handlers) != 1 || handlers[0] != "load" {
+				t.Errorf("hooks not applied: wrapped=%v handlers=%v", wrapped, handlers)
+			}
+		})
+	}
+}
+
+func TestHandlerOverrides(t *testing.T) {
+	app := &App{}
+	called := false
+	h := app.Handler(map[string]http.HandlerFunc{
+		"/health": func(http.ResponseWriter, *http.Request) { called = true },
+	})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if !called {
+		t.Error("override was not used")
+	}
+}
+
+func TestHandlerProfiling(t *testing.T) {
+	for _, profiling := range []bool{false, true} {
+		wrapped := false
+		app := &App{Input: schema.Input{Profiling: profiling}, Hooks: Hooks{
+			Middleware: func(h http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					wrapped = true
+					h.ServeHTTP(w, r)
+				})
+			},
+		}}
+		w := httptest.NewRecorder()
+		app.Handler(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine", nil))
+		if got := w.Code == http.StatusOK; got != profiling {
+			t.Errorf("profiling=%v: status = %d", profiling, w.Code)
+		}
+		if wrapped == profiling {
+			t.Errorf("profiling=%v: middleware called = %v", profiling, wrapped)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"fosdem2026/app/schema"
+)
+
+// archetypes maps an archetype name to the workload shape it stands for. Only
+// the load and workload knobs are set here; environment settings such as Port
+// and RuntimeVersion are filled in by NewInput.
+var archetypes = map[string]Input{
+	// idle barely touches the app: a single request per second doing no work.
+	// It isolates the fixed cost of an instrumentation (sidecars, exporters).
+	"idle": {
+		RPS:      1,
+		Duration: 30,
+	},
+	// throughput saturates the app with closed-loop clients doing a little
+	// CPU work and a few allocations per request.
+	"throughput": {
+		Clients: 16,
+		Input: schema.Input{
+			LoopsCPU:   0.0005,
+			AllocsCPU:  0.0002,
+			AllocsSize: 64,
+		},
+		Duration: 60,
+	},
+	// latency drives a steady open-loop rate with mostly off-CPU handlers, so
+	// that per-request overhead shows up as tail latency.
+	"latency": {
+		RPS: 200,
+		Input: schema.Input{
+			LoopsCPU: 0.0001,
+			OffCPU:   0.001,
+		},
+		Duration: 60,
+	},
+	// enterprise resembles a typical service: moderate rate, a mix of CPU,
+	// allocations and waiting on downstream dependencies, across several
+	// workers.
+	"enterprise": {
+		RPS: 500,
+		Input: schema.Input{
+			Workers:    4,
+			LoopsCPU:   0.002,
+			AllocsCPU:  0.001,
+			AllocsSize: 1024,
+			OffCPU:     0.01,
+		},
+		Duration: 60,
+	},
+}
+
+// Archetypes returns the names of all known archetypes in sorted order.
+func Archetypes() []string {
+	names := make([]string, 0, len(archetypes))
+	for name := range archetypes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewInput expands the given archetype into a complete Input. The returned
+// Input is a fresh copy and can be modified by the caller.
+func NewInput(archetype string) (*Input, error) {
+	preset, ok := archetypes[archetype]
+	if !ok {
+		return nil, fmt.Errorf("unknown archetype %q (available: %s)", archetype, strings.Join(Archetypes(), ", "))
+	}
+	input := preset
+	input.Archetype = archetype
+	input.Version = schema.Version
+	input.Port = 8080
+	input.RuntimeVersion = "1.25.5"
+	input.Flush = true
+	input.Timeout = 5
+	return &input, nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// hashInputs returns a stable hash identifying a test configuration: the
+// inputs (without their hash), the scenario, the image digests of the
+// scenario and its sidecars and whether requests are dropped from the
+// results. Results with the same hash are interchangeable.
+func hashInputs(inputs *Input, scenario, imageDigest string, sidecarDigests []string, histogramsOnly bool) (string, error) {
+	in := *inputs
+	in.Hash = ""
+	data, err := json.Marshal(struct {
+		Inputs         *Input   `json:"inputs"`
+		Scenario       string   `json:"scenario"`
+		ImageDigest    string   `json:"image_digest"`
+		SidecarDigests []string `json:"sidecar_digests,omitempty"`
+		HistogramsOnly bool     `json:"histograms_only,omitempty"`
+	}{&in, scenario, imageDigest, sidecarDigests, histogramsOnly})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// resultsPath returns the path under which the result of the given run is
+// stored.
+func resultsPath(dir, hash string, run int) string {
+	return filepath.Join(dir, hash, strconv.Itoa(run)+".json")
+}
+
+// runDir returns the directory holding the files the given run collected
+// besides its result, e.g. profiles.
+func runDir(dir, hash string, run int) string {
+	return filepath.Join(dir, hash, strconv.Itoa(run))
+}
+
+// loadResults reads all cached results for the given hash, ordered by run.
+// A missing cache directory is not an error.
+func loadResults(dir, hash string) ([]*TestResult, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	results := []*TestResult{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, hash, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var r TestResult
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		results = append(results, &r)
+	}
+	slices.SortFunc(results, func(a, b *TestResult) int { return a.Run - b.Run })
+	return results, nil
+}
+
+// saveResult writes a result to the cache, replacing any previous result for
+// the same run.
+func saveResult(dir string, r *TestResult) error {
+	path := resultsPath(dir, r.Hash, r.Run)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
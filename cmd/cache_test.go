@@ -0,0 +1,84 @@
+package cmd
+
+import "testing"
+
+func TestHashInputs(t *testing.T) {
+	inputs, err := NewInput("idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hashInputs(inputs, "default", "sha256:abc", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hash must not depend on the previously computed hash.
+	inputs.Hash = hash
+	again, err := hashInputs(inputs, "default", "sha256:abc", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != hash {
+		t.Errorf("hash changed after setting Input.Hash: %s != %s", again, hash)
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(*Input)
+		scenario       string
+		digest         string
+		sidecars       []string
+		histogramsOnly bool
+	}{
+		{name: "scenario", scenario: "manual", digest: "sha256:abc"},
+		{name: "digest", scenario: "default", digest: "sha256:def"},
+		{name: "inputs", modify: func(in *Input) { in.RPS++ }, scenario: "default", digest: "sha256:abc"},
+		{name: "sidecar digests", scenario: "default", digest: "sha256:abc", sidecars: []string{"sha256:123"}},
+		{name: "histograms only", scenario: "default", digest: "sha256:abc", histogramsOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := *inputs
+			if tt.modify != nil {
+				tt.modify(&in)
+			}
+			got, err := hashInputs(&in, tt.scenario, tt.digest, tt.sidecars, tt.histogramsOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == hash {
+				t.Errorf("expected hash to change when %s changes", tt.name)
+			}
+		})
+	}
+}
+
+func TestSaveLoadResults(t *testing.T) {
+	dir := t.TempDir()
+
+	results, err := loadResults(dir, "missing")
+	if err != nil {
+		t.Fatalf("loading a missing cache: %v", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("expected no results, got %d", len(results))
+	}
+
+	for _, run := range []int{2, 0, 1} {
+		if err := saveResult(dir, &TestResult{Scenario: "default", Hash: "abc", Run: run}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err = loadResults(dir, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.Run != i {
+			t.Errorf("results[%d].Run = %d, want %d", i, r.Run, i)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// CompareOpts holds options for comparing scenarios.
+type CompareOpts struct {
+	// Alpha is the significance level, e.g. 0.05 for 95% confidence.
+	Alpha float64
+	// Resamples is the number of bootstrap resamples used for the confidence
+	// intervals.
+	Resamples int
+	// Seed makes the bootstrap reproducible.
+	Seed uint64
+}
+
+// Comparison holds the result of comparing one metric between two scenarios.
+type Comparison struct {
+	Metric string
+	A, B   string
+	// NA and NB are the number of runs of A and B.
+	NA, NB int
+	// MeanA and MeanB are the means of the per-run values.
+	MeanA, MeanB float64
+	// Low and High bound the confidence interval of MeanB - MeanA.
+	Low, High float64
+	// U is the Mann-Whitney U statistic of A and P its two-sided p-value.
+	U, P float64
+	// Significant is set if both the confidence interval excludes zero and
+	// P is below the significance level.
+	Significant bool
+}
+
+// metric extracts a per-run value from the summary of a single run.
+type metric struct {
+	name  string
+	value func(*Summary) float64
+}
+
+var compareMetrics = []metric{
+	{"p50 (ms)", func(s *Summary) float64 { return float64(s.P50) / float64(time.Millisecond) }},
+	{"p99 (ms)", func(s *Summary) float64 { return float64(s.P99) / float64(time.Millisecond) }},
+	{"cpu (%)", func(s *Summary) float64 { return s.CPU }},
+}
+
+// maxRPSMetric compares the maximum sustainable throughput of runs with
+// --find-max.
+var maxRPSMetric = metric{"max (req/s)", func(s *Summary) float64 { return s.MaxRPS }}
+
+// Compare compares every pair of scenarios on the per-run values of each
+// metric. Each run contributes one sample, so scenarios need to be repeated
+// (--num) for the comparison to mean anything.
+func Compare(results []*TestResult, opts *CompareOpts) []*Comparison {
+	metrics := compareMetrics
+	for _, r := range results {
+		if r.Inputs != nil && r.Inputs.FindMax != nil {
+			metrics = append(slices.Clip(compareMetrics), maxRPSMetric)
+			break
+		}
+	}
+
+	samples := map[string]map[string][]float64{}
+	order := []string{}
+	for _, r := range results {
+		s := summarize(resultLabel(r), []*TestResult{r})
+		if _, ok := samples[s.Scenario]; !ok {
+			samples[s.Scenario] = map[string][]float64{}
+			order = append(order, s.Scenario)
+		}
+		for _, m := range metrics {
+			if m.name == maxRPSMetric.name && s.MaxRPSRuns == 0 {
+				continue
+			}
+			samples[s.Scenario][m.name] = append(samples[s.Scenario][m.name], m.value(s))
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed))
+	comparisons := []*Comparison{}
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			for _, m := range metrics {
+				xs, ys := samples[a][m.name], samples[b][m.name]
+				c := &Comparison{
+					Metric: m.name,
+					A:      a,
+					B:      b,
+					NA:     len(xs),
+					NB:     len(ys),
+					MeanA:  mean(xs),
+					MeanB:  mean(ys),
+				}
+				c.Low, c.High = bootstrapCI(xs, ys, opts.Resamples, opts.Alpha, rng)
+				c.U, c.P = mannWhitneyU(xs, ys)
+				c.Significant = c.P < opts.Alpha && (c.Low > 0 || c.High < 0)
+				comparisons = append(comparisons, c)
+			}
+		}
+	}
+	return comparisons
+}
+
+// comparisonTable renders the comparisons as rows of cells, with a header row
+// first.
+func comparisonTable(comparisons []*Comparison) [][]string {
+	rows := [][]string{{
+		"metric", "a", "b", "runs a", "runs b", "mean a", "mean b",
+		"diff", "ci low", "ci high", "p-value", "significant",
+	}}
+	for _, c := range comparisons {
+		significant := "no"
+		if c.Significant {
+			significant = "yes"
+		}
+		rows = append(rows, []string{
+			c.Metric,
+			c.A,
+			c.B,
+			fmt.Sprint(c.NA),
+			fmt.Sprint(c.NB),
+			fmt.Sprintf("%.3f", c.MeanA),
+			fmt.Sprintf("%.3f", c.MeanB),
+			formatOverhead(c.MeanB, c.MeanA),
+			fmt.Sprintf("%+.3f", c.Low),
+			fmt.Sprintf("%+.3f", c.High),
+			fmt.Sprintf("%.4f", c.P),
+			significant,
+		})
+	}
+	return rows
+}
+
+// bootstrapCI returns the percentile bootstrap confidence interval at level
+// 1-alpha for the difference of means mean(ys) - mean(xs).
+func bootstrapCI(xs, ys []float64, resamples int, alpha float64, rng *rand.Rand) (float64, float64) {
+	if len(xs) == 0 || len(ys) == 0 || resamples <= 0 {
+		return math.NaN(), math.NaN()
+	}
+	diffs := make([]float64, resamples)
+	for i := range diffs {
+		diffs[i] = resampledMean(ys, rng) - resampledMean(xs, rng)
+	}
+	slices.Sort(diffs)
+	low := diffs[int(math.Floor(alpha/2*float64(resamples-1)))]
+	high := diffs[int(math.Ceil((1-alpha/2)*float64(resamples-1)))]
+	return low, high
+}
+
+func resampledMean(xs []float64, rng *rand.Rand) float64 {
+	var sum float64
+	for range xs {
+		sum += xs[rng.IntN(len(xs))]
+	}
+	return sum / float64(len(xs))
+}
+
+// mannWhitneyU returns the Mann-Whitney U statistic of xs and the two-sided
+// p-value of the null hypothesis that xs and ys come from the same
+// distribution. The p-value uses the normal approximation with tie and
+// continuity correction, which is reasonable from about five runs per
+// scenario.
+func mannWhitneyU(xs, ys []float64) (float64, float64) {
+	n1, n2 := float64(len(xs)), float64(len(ys))
+	if n1 == 0 || n2 == 0 {
+		return math.NaN(), 1
+	}
+
+	// Rank the pooled samples, assigning tied values their average rank.
+	type sample struct {
+		value float64
+		first bool
+	}
+	pooled := make([]sample, 0, len(xs)+len(ys))
+	for _, x := range xs {
+		pooled = append(pooled, sample{x, true})
+	}
+	for _, y := range ys {
+		pooled = append(pooled, sample{y, false})
+	}
+	slices.SortFunc(pooled, func(a, b sample) int { return cmp.Compare(a.value, b.value) })
+
+	var rankSum, tieTerm float64
+	for i := 0; i < len(pooled); {
+		j := i
+		for j < len(pooled) && pooled[j].value == pooled[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2 // average of the 1-based ranks i+1..j
+		for k := i; k < j; k++ {
+			if pooled[k].first {
+				rankSum += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	u := rankSum - n1*(n1+1)/2
+	n := n1 + n2
+	mu := n1 * n2 / 2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	p := math.Erfc(max(z, 0) / math.Sqrt2)
+	return u, min(p, 1)
+}
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name  string
+		xs    []float64
+		ys    []float64
+		wantU float64
+		wantP float64
+	}{
+		{
+			name:  "separated",
+			xs:    []float64{1, 2, 3, 4, 5},
+			ys:    []float64{6, 7, 8, 9, 10},
+			wantU: 0,
+			wantP: 0.01219,
+		},
+		{
+			name:  "identical",
+			xs:    []float64{1, 1, 1},
+			ys:    []float64{1, 1, 1},
+			wantU: 4.5,
+			wantP: 1,
+		},
+		{
+			name:  "interleaved",
+			xs:    []float64{1, 3, 5, 7},
+			ys:    []float64{2, 4, 6, 8},
+			wantU: 6,
+			wantP: 0.6650,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, p := mannWhitneyU(tt.xs, tt.ys)
+			if u != tt.wantU {
+				t.Errorf("U = %v, want %v", u, tt.wantU)
+			}
+			if math.Abs(p-tt.wantP) > 1e-4 {
+				t.Errorf("p = %v, want %v", p, tt.wantP)
+			}
+		})
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	xs := []float64{10, 11, 9, 10, 10}
+	ys := []float64{20, 21, 19, 20, 20}
+	low, high := bootstrapCI(xs, ys, 1000, 0.05, rng)
+	if low > 10 || high < 10 || low <= 0 {
+		t.Errorf("CI = [%v, %v], expected it to contain 10 and exclude 0", low, high)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	start := time.Unix(0, 0)
+	run := func(scenario string, latency time.Duration) *TestResult {
+		return &TestResult{
+			Scenario:  scenario,
+			LoadStart: start,
+			LoadEnd:   start.Add(time.Second),
+			Requests:  []Request{{Duration: latency}},
+		}
+	}
+	results := []*TestResult{}
+	for i := range 6 {
+		jitter := time.Duration(i) * time.Microsecond
+		results = append(results,
+			run("default", time.Millisecond+jitter),
+			run("manual", 2*time.Millisecond+jitter),
+			run("obi", time.Millisecond+2*jitter),
+		)
+	}
+
+	comparisons := Compare(results, &CompareOpts{Alpha: 0.05, Resamples: 1000, Seed: 1})
+	significant := map[string]bool{}
+	for _, c := range comparisons {
+		if c.Metric == "p50 (ms)" {
+			significant[c.A+"/"+c.B] = c.Significant
+		}
+	}
+	if !significant["default/manual"] {
+		t.Error("expected default/manual p50 difference to be significant")
+	}
+	if significant["default/obi"] {
+		t.Error("expected default/obi p50 difference not to be significant")
+	}
+}
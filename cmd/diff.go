@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"github.com/urfave/cli/v3"
+)
+
+// CmdDiff is the CLI command for comparing the profiles of two scenarios.
+var CmdDiff = &cli.Command{
+	Name:      "diff",
+	Usage:     "compares the profiles of a scenario with those of the baseline",
+	ArgsUsage: "[results.json...]",
+	Description: `
+	Merge the profiles of the given kind that "run --profiling" collected for every run
+	of --scenario and of --baseline, each averaged per run, and output their difference.
+
+	With --format pprof (the default), the output is a pprof profile of the scenario
+	minus the baseline, whose samples from the baseline are negative and labeled
+	pprof::base, e.g. for "go tool pprof -http : diff.pb.gz".
+
+	With --format folded, the output has a line "frame;frame;frame baseline scenario"
+	per stack, which flamegraph.pl renders as a differential flame graph.
+
+	Scenarios are matched like in report, so matrix cells are given by their label.
+	Reads from stdin if no files are given.
+	`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "scenario",
+			Aliases:  []string{"s"},
+			Usage:    "The scenario (or matrix cell) to compare with the baseline",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "baseline",
+			Usage: "The scenario (or matrix cell) to compare against",
+			Value: "default",
+		},
+		&cli.StringFlag{
+			Name:  "kind",
+			Usage: "The kind of profile to compare (" + strings.Join(profileKinds, ", ") + ")",
+			Value: "cpu",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format (pprof, folded)",
+			Value: "pprof",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Write the difference to this file instead of stdout",
+		},
+	},
+	Action: func(_ context.Context, c *cli.Command) error {
+		results, err := readResults(c.Args().Slice(), c.Reader)
+		if err != nil {
+			return err
+		}
+		format := c.String("format")
+		if format != "pprof" && format != "folded" {
+			return fmt.Errorf("unknown format %q", format)
+		}
+		base, err := mergeProfiles(results, c.String("baseline"), c.String("kind"))
+		if err != nil {
+			return err
+		}
+		target, err := mergeProfiles(results, c.String("scenario"), c.String("kind"))
+		if err != nil {
+			return err
+		}
+
+		path := c.String("output")
+		if path == "" {
+			return writeDiff(c.Writer, format, base, target)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		err = writeDiff(f, format, base, target)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	},
+}
+
+// writeDiff writes the difference of target and base in the given format.
+func writeDiff(w io.Writer, format string, base, target *profile.Profile) error {
+	if format == "folded" {
+		return writeFoldedDiff(w, base, target)
+	}
+	diff, err := diffProfiles(base, target)
+	if err != nil {
+		return err
+	}
+	return diff.Write(w)
+}
+
+// mergeProfiles merges the profiles of the given kind of all runs of a
+// scenario and scales the result down to the average run.
+func mergeProfiles(results []*TestResult, label, kind string) (*profile.Profile, error) {
+	var profiles []*profile.Profile
+	for _, r := range results {
+		if resultLabel(r) != label {
+			continue
+		}
+		for _, p := range r.Profiles {
+			if p.Kind != kind || p.Error != "" {
+				continue
+			}
+			for _, path := range p.Files {
+				prof, err := readProfile(path)
+				if err != nil {
+					return nil, err
+				}
+				profiles = append(profiles, prof)
+			}
+		}
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no %s profiles for %s, run it with --profiling", kind, label)
+	}
+	merged, err := profile.Merge(profiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", label, err)
+	}
+	merged.Scale(1 / float64(len(profiles)))
+	return merged, nil
+}
+
+func readProfile(path string) (*profile.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// diffProfiles returns target minus base the way "pprof -diff_base" does:
+// the samples of base are negated and labeled pprof::base.
+func diffProfiles(base, target *profile.Profile) (*profile.Profile, error) {
+	base = base.Copy()
+	base.Scale(-1)
+	for _, s := range base.Sample {
+		if s.Label == nil {
+			s.Label = map[string][]string{}
+		}
+		s.Label["pprof::base"] = []string{"true"}
+	}
+	return profile.Merge([]*profile.Profile{target, base})
+}
+
+// writeFoldedDiff writes a line "frame;frame;frame base target" per stack,
+// root first and sorted by stack, with the values of the default sample type.
+func writeFoldedDiff(w io.Writer, base, target *profile.Profile) error {
+	values := map[string][2]int64{}
+	for i, p := range []*profile.Profile{base, target} {
+		index := sampleIndex(p)
+		for _, s := range p.Sample {
+			stack := foldStack(s)
+			v := values[stack]
+			v[i] += s.Value[index]
+			values[stack] = v
+		}
+	}
+	stacks := make([]string, 0, len(values))
+	for stack := range values {
+		stacks = append(stacks, stack)
+	}
+	slices.Sort(stacks)
+	for _, stack := range stacks {
+		v := values[stack]
+		if _, err := fmt.Fprintf(w, "%s %d %d\n", stack, v[0], v[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleIndex returns the index of the default sample type of the profile,
+// or of the last one, which is what pprof shows by default, e.g. the CPU
+// time rather than the number of samples.
+func sampleIndex(p *profile.Profile) int {
+	for i, st := range p.SampleType {
+		if st.Type == p.DefaultSampleType {
+			return i
+		}
+	}
+	return len(p.SampleType) - 1
+}
+
+// foldStack returns the functions of the sample's stack, root first and
+// separated by semicolons, with inlined functions as frames of their own.
+func foldStack(s *profile.Sample) string {
+	var frames []string
+	for _, loc := range s.Location {
+		// Lines are listed innermost (inlined) first, like locations.
+		for _, line := range loc.Line {
+			name := "?"
+			if line.Function != nil {
+				name = line.Function.Name
+			}
+			frames = append(frames, name)
+		}
+		if len(loc.Line) == 0 {
+			frames = append(frames, fmt.Sprintf("0x%x", loc.Address))
+		}
+	}
+	slices.Reverse(frames)
+	return strings.Join(frames, ";")
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestDiffProfiles(t *testing.T) {
+	handler := &profile.Function{ID: 1, Name: "main.handler"}
+	otel := &profile.Function{ID: 2, Name: "otelhttp.ServeHTTP"}
+	// newProfile returns a CPU profile of cpu nanoseconds in main.handler, and
+	// instrumented ones in otelhttp.ServeHTTP called from it.
+	newProfile := func(cpu, instrumented int64) *profile.Profile {
+		locHandler := &profile.Location{ID: 1, Line: []profile.Line{{Function: handler}}}
+		locOTel := &profile.Location{ID: 2, Line: []profile.Line{{Function: otel}}}
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+			PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+			Period:     10000000,
+			Function:   []*profile.Function{handler, otel},
+			Location:   []*profile.Location{locHandler, locOTel},
+			Sample:     []*profile.Sample{{Location: []*profile.Location{locHandler}, Value: []int64{1, cpu}}},
+		}
+		if instrumented > 0 {
+			p.Sample = append(p.Sample, &profile.Sample{Location: []*profile.Location{locOTel, locHandler}, Value: []int64{1, instrumented}})
+		}
+		return p
+	}
+	dir := t.TempDir()
+	result := func(scenario string, run int, p *profile.Profile) *TestResult {
+		path := filepath.Join(dir, scenario+"-"+strconv.Itoa(run)+".pprof")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Write(f); err != nil {
+			t.Fatal(err)
+		}
+		_ = f.Close()
+		return &TestResult{Scenario: scenario, Run: run, Profiles: []*ProfilePayload{
+			{Kind: "cpu", Files: []string{path}},
+			{Kind: "heap", Error: "unexpected status 404"},
+		}}
+	}
+	results := []*TestResult{
+		result("default", 0, newProfile(100, 0)),
+		result("default", 1, newProfile(300, 0)),
+		result("manual", 0, newProfile(200, 50)),
+	}
+
+	base, err := mergeProfiles(results, "default", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := mergeProfiles(results, "manual", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeProfiles(results, "manual", "heap"); err == nil {
+		t.Error("expected an error for missing heap profiles")
+	}
+
+	var folded strings.Builder
+	if err := writeFoldedDiff(&folded, base, target); err != nil {
+		t.Fatal(err)
+	}
+	want := "main.handler 200 200\nmain.handler;otelhttp.ServeHTTP 0 50\n"
+	if folded.String() != want {
+		t.Errorf("folded = %q, want %q", folded.String(), want)
+	}
+
+	diff, err := diffProfiles(base, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, s := range diff.Sample {
+		total += s.Value[1]
+	}
+	if total != 50 {
+		t.Errorf("diff total = %d, want 50", total)
+	}
+}
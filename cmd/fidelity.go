@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// matchTolerance is how far outside of a request a span may start and
+	// still be matched with it, to allow for clock resolution.
+	matchTolerance = time.Millisecond
+	// durationTolerance is how much the duration of a span may differ from
+	// the service time the load generator measured, at least. The client also
+	// measures the network and the Docker port forwarding.
+	durationTolerance = 2 * time.Millisecond
+	// durationRelTolerance is the same relative to the service time, which
+	// applies to requests slower than durationTolerance/durationRelTolerance.
+	durationRelTolerance = 0.1
+)
+
+// Fidelity measures how accurately the spans a scenario exported during load
+// describe the requests the load generator sent.
+type Fidelity struct {
+	Requests int `json:"requests"`
+	// Spans is the number of root spans that started during load, of which
+	// Matched could be matched with a request.
+	Spans   int `json:"spans"`
+	Matched int `json:"matched"`
+	// Name, Method, Path, Status and Duration count the matched spans that
+	// got the respective field right.
+	Name     int `json:"name"`
+	Method   int `json:"method"`
+	Path     int `json:"path"`
+	Status   int `json:"status"`
+	Duration int `json:"duration"`
+	// Score is the fraction of correct fields over all requests and spans,
+	// with unmatched requests and spans counting as all wrong. It is 1 if
+	// every request was traced exactly once and correctly.
+	Score float64 `json:"score"`
+}
+
+// fidelityChecks is the number of fields checked per span.
+const fidelityChecks = 5
+
+// validateFidelity matches the root spans with the requests sent to the
+// endpoints of mix and checks whether they describe them correctly. A span
+// is matched with the unmatched request to the same path that was sent
+// closest to its start, among those in flight when it started.
+func validateFidelity(requests []Request, mix []Endpoint, spans []receivedSpan) *Fidelity {
+	f := &Fidelity{Requests: len(requests), Spans: len(spans)}
+	endpoints := map[string]*Endpoint{}
+	mix = normalizeMix(mix)
+	for i := range mix {
+		endpoints[mix[i].Name] = &mix[i]
+	}
+
+	type sentRequest struct {
+		*Request
+		sent    time.Time
+		method  string
+		path    string
+		matched bool
+	}
+	sent := make([]*sentRequest, 0, len(requests))
+	var longest time.Duration
+	for i := range requests {
+		r := &sentRequest{Request: &requests[i], sent: requests[i].End.Add(-requests[i].Duration)}
+		if e, ok := endpoints[r.Endpoint]; ok {
+			r.method = e.Method
+			r.path, _, _ = strings.Cut(e.Path, "?")
+		}
+		sent = append(sent, r)
+		longest = max(longest, r.Duration)
+	}
+	slices.SortFunc(sent, func(a, b *sentRequest) int { return a.sent.Compare(b.sent) })
+
+	spans = slices.Clone(spans)
+	slices.SortFunc(spans, func(a, b receivedSpan) int { return a.Start.Compare(b.Start) })
+	for _, span := range spans {
+		// Requests sent after the span started, give or take the tolerance,
+		// cannot be the span's.
+		end, _ := slices.BinarySearchFunc(sent, span.Start.Add(matchTolerance), func(r *sentRequest, t time.Time) int {
+			return r.sent.Compare(t)
+		})
+		var match *sentRequest
+		for i := end - 1; i >= 0 && span.Start.Sub(sent[i].sent) <= longest+matchTolerance; i-- {
+			r := sent[i]
+			if r.matched || r.End.Add(matchTolerance).Before(span.Start) {
+				continue
+			}
+			if span.Path != "" && r.path != "" && span.Path != r.path {
+				continue
+			}
+			match = r
+			break
+		}
+		if match == nil {
+			continue
+		}
+		match.matched = true
+		f.Matched++
+		if spanNameMatches(span.Name, match.method) {
+			f.Name++
+		}
+		if span.Method == match.method {
+			f.Method++
+		}
+		if span.Path == match.path {
+			f.Path++
+		}
+		if span.Status == match.Status {
+			f.Status++
+		}
+		diff := (span.End.Sub(span.Start) - match.Duration).Abs()
+		if diff <= max(durationTolerance, time.Duration(float64(match.Duration)*durationRelTolerance)) {
+			f.Duration++
+		}
+	}
+
+	if total := f.Requests + f.Spans - f.Matched; total > 0 {
+		correct := f.Name + f.Method + f.Path + f.Status + f.Duration
+		f.Score = float64(correct) / float64(fidelityChecks*total)
+	}
+	return f
+}
+
+// spanNameMatches reports whether name is a valid name for a span of an HTTP
+// server handling a request, which is "{method} {route}" or just "{method}"
+// if the route is unknown. Since the runner doesn't know the routes, any
+// route is accepted.
+func spanNameMatches(name, method string) bool {
+	return name == method || strings.HasPrefix(name, method+" /")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateFidelity(t *testing.T) {
+	start := time.Unix(100, 0)
+	request := func(sent, d time.Duration, endpoint string, status int) Request {
+		return Request{Endpoint: endpoint, Status: status, End: start.Add(sent + d), Duration: d}
+	}
+	span := func(name, method, path string, status int, from, d time.Duration) receivedSpan {
+		return receivedSpan{Name: name, Method: method, Path: path, Status: status, Start: start.Add(from), End: start.Add(from + d)}
+	}
+	mix := []Endpoint{{Name: "load", Path: "/load"}, {Name: "write", Method: "post", Path: "/write?n=1"}}
+	requests := []Request{
+		request(0, 10*time.Millisecond, "load", 200),
+		// Overlaps with the first request, on another path.
+		request(time.Millisecond, 20*time.Millisecond, "write", 201),
+		request(50*time.Millisecond, 10*time.Millisecond, "load", 200),
+		// Not traced.
+		request(100*time.Millisecond, 10*time.Millisecond, "load", 200),
+	}
+
+	tests := []struct {
+		name  string
+		spans []receivedSpan
+		want  Fidelity
+	}{
+		{
+			name: "exact",
+			spans: []receivedSpan{
+				span("GET /load", "GET", "/load", 200, 100*time.Microsecond, 9*time.Millisecond),
+				span("POST /write", "POST", "/write", 201, 2*time.Millisecond, 18*time.Millisecond),
+				span("GET", "GET", "/load", 200, 51*time.Millisecond, 9*time.Millisecond),
+				span("GET", "GET", "/load", 200, 101*time.Millisecond, 9*time.Millisecond),
+			},
+			want: Fidelity{Requests: 4, Spans: 4, Matched: 4, Name: 4, Method: 4, Path: 4, Status: 4, Duration: 4, Score: 1},
+		},
+		{
+			name: "missing and wrong",
+			spans: []receivedSpan{
+				// Too short, and named after the handler.
+				span("handler", "GET", "/load", 200, 100*time.Microsecond, 2*time.Millisecond),
+				span("POST /write", "POST", "/write", 500, 2*time.Millisecond, 18*time.Millisecond),
+				span("GET", "GET", "/load", 200, 51*time.Millisecond, 9*time.Millisecond),
+				// Doesn't belong to any request.
+				span("GET", "GET", "/load", 200, time.Second, 9*time.Millisecond),
+			},
+			want: Fidelity{Requests: 4, Spans: 4, Matched: 3, Name: 2, Method: 3, Path: 3, Status: 2, Duration: 2, Score: 12.0 / 25},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateFidelity(requests, mix, tt.spans)
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
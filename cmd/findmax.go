@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FindMaxOpts configures the search for the maximum sustainable throughput
+// of a scenario.
+type FindMaxOpts struct {
+	// SLOP99 is the highest acceptable p99 response time in seconds.
+	SLOP99 float64 `json:"slo_p99"`
+	// MaxErrorRate is the highest acceptable fraction of failed requests.
+	MaxErrorRate float64 `json:"max_error_rate"`
+	// MaxRPS caps the search, so a scenario that sustains it is reported as
+	// sustaining exactly MaxRPS.
+	MaxRPS int `json:"max_rps"`
+	// ProbeDuration is how long each probe puts the application under load,
+	// in seconds.
+	ProbeDuration float64 `json:"probe_duration"`
+}
+
+// Probe is the outcome of putting the application under a constant load for
+// one step of the search.
+type Probe struct {
+	RPS      int   `json:"rps"`
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	// P99 is the p99 response time, which includes queueing delay once the
+	// application falls behind.
+	P99 time.Duration `json:"p99"`
+	// OK is set if the probe met the SLO and the error rate threshold.
+	OK bool `json:"ok"`
+}
+
+// ErrorRate returns the fraction of failed requests.
+func (p *Probe) ErrorRate() float64 {
+	if p.Requests == 0 {
+		return 0
+	}
+	return float64(p.Errors) / float64(p.Requests)
+}
+
+// findMaxTolerance is the precision of the search relative to the rate found,
+// i.e. the search stops once the highest passing and lowest failing rate are
+// within 5% of each other.
+const findMaxTolerance = 20
+
+// findMax returns the highest rate, in requests per second, at which probe
+// meets the SLO and error rate threshold, along with all probes in the order
+// they were run. It doubles the rate from start until a probe fails or
+// opts.MaxRPS is reached, then bisects between the last passing and the
+// first failing rate. A rate of zero means that even a single request per
+// second failed.
+func findMax(ctx context.Context, opts *FindMaxOpts, start int, probe func(ctx context.Context, rps int) (*Probe, error)) (int, []*Probe, error) {
+	if opts.MaxRPS < 1 {
+		return 0, nil, fmt.Errorf("max rps must be positive")
+	}
+	slo := time.Duration(opts.SLOP99 * float64(time.Second))
+	probes := []*Probe{}
+	run := func(rps int) (bool, error) {
+		p, err := probe(ctx, rps)
+		if err != nil {
+			return false, err
+		}
+		p.RPS = rps
+		p.OK = p.Requests > 0 && p.P99 <= slo && p.ErrorRate() <= opts.MaxErrorRate
+		probes = append(probes, p)
+		return p.OK, nil
+	}
+
+	// low is the highest passing rate and high the lowest failing one, or
+	// zero while none failed yet.
+	low, high := 0, 0
+	rps := min(max(start, 1), opts.MaxRPS)
+	for high == 0 {
+		ok, err := run(rps)
+		if err != nil {
+			return low, probes, err
+		}
+		if !ok {
+			high = rps
+			break
+		}
+		low = rps
+		if rps == opts.MaxRPS {
+			return low, probes, nil
+		}
+		rps = min(rps*2, opts.MaxRPS)
+	}
+	for high-low > max(1, low/findMaxTolerance) {
+		mid := (low + high) / 2
+		ok, err := run(mid)
+		if err != nil {
+			return low, probes, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low, probes, nil
+}
+
+// probeCooldown is how long to wait after a probe, so that requests queued
+// in the application by an overloading probe don't affect the next one.
+const probeCooldown = 2 * time.Second
+
+// runProbe puts the application under a constant load of rps requests per
+// second for opts.ProbeDuration. Failed requests count against the error rate
+// rather than failing the probe.
+func runProbe(ctx context.Context, config *Config, opts *FindMaxOpts, rps int) (*Probe, error) {
+	c := *config
+	c.RPS = rps
+	c.Clients = 0
+	c.Stages = nil
+	c.Duration = opts.ProbeDuration
+	c.HistogramsOnly = true
+	c.WarmUp = 0
+	load, err := Generate(ctx, &c)
+	if load == nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return &Probe{
+		Requests: load.Latency.Response.Count(),
+		Errors:   load.Latency.Errors,
+		P99:      load.Latency.Response.Quantile(0.99),
+	}, nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFindMax(t *testing.T) {
+	// The application sustains up to capacity requests per second, beyond
+	// which its p99 grows past the SLO.
+	probe := func(capacity int) func(context.Context, int) (*Probe, error) {
+		return func(_ context.Context, rps int) (*Probe, error) {
+			p := &Probe{Requests: int64(rps), P99: 10 * time.Millisecond}
+			if rps > capacity {
+				p.P99 = time.Second
+			}
+			return p, nil
+		}
+	}
+	opts := &FindMaxOpts{SLOP99: 0.1, MaxErrorRate: 0.01, MaxRPS: 10000}
+
+	tests := []struct {
+		name     string
+		capacity int
+		start    int
+		min, max int
+	}{
+		{"search", 700, 10, 700 * 19 / 20, 700},
+		{"capped", 20000, 10, 10000, 10000},
+		{"overloaded", 0, 10, 0, 0},
+		{"start above capacity", 50, 200, 48, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, probes, err := findMax(context.Background(), opts, tt.start, probe(tt.capacity))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got < tt.min || got > tt.max {
+				t.Errorf("got %d, want between %d and %d", got, tt.min, tt.max)
+			}
+			for _, p := range probes {
+				if p.OK != (p.RPS <= tt.capacity) {
+					t.Errorf("probe at %d rps: ok=%v", p.RPS, p.OK)
+				}
+			}
+		})
+	}
+}
+
+func TestFindMaxErrorRate(t *testing.T) {
+	opts := &FindMaxOpts{SLOP99: 1, MaxErrorRate: 0.01, MaxRPS: 1000}
+	got, _, err := findMax(context.Background(), opts, 1, func(_ context.Context, rps int) (*Probe, error) {
+		p := &Probe{Requests: 1000}
+		if rps > 100 {
+			p.Errors = 20
+		}
+		return p, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got < 95 || got > 100 {
+		t.Errorf("got %d, want about 100", got)
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// Histogram bucket layout, following HdrHistogram: values are recorded in
+// microseconds into buckets of 2048 sub-buckets each, where every bucket
+// covers twice the range of the previous one at half the resolution. This
+// keeps three significant digits for any value.
+const (
+	subBucketBits     = 11
+	subBucketHalfBits = subBucketBits - 1
+	subBucketCount    = 1 << subBucketBits
+	subBucketHalf     = 1 << subBucketHalfBits
+	subBucketMask     = subBucketCount - 1
+)
+
+// Histogram is a high dynamic range histogram of durations with microsecond
+// resolution and three significant digits of precision. Only non-empty
+// buckets are stored, so its size depends on the spread of the recorded
+// values rather than their number. It is not safe for concurrent use.
+type Histogram struct {
+	counts map[int32]int64
+	count  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+// NewHistogram returns an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{counts: map[int32]int64{}}
+}
+
+// Record adds a duration to the histogram. Negative durations are recorded
+// as zero.
+func (h *Histogram) Record(d time.Duration) {
+	v := max(d.Microseconds(), 0)
+	h.counts[bucketIndex(v)]++
+	if h.count == 0 || v < h.min {
+		h.min = v
+	}
+	h.max = max(h.max, v)
+	h.count++
+	h.sum += v
+}
+
+// Merge adds all values recorded in o to h.
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil || o.count == 0 {
+		return
+	}
+	for idx, n := range o.counts {
+		h.counts[idx] += n
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	h.max = max(h.max, o.max)
+	h.count += o.count
+	h.sum += o.sum
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the smallest recorded value.
+func (h *Histogram) Min() time.Duration {
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Mean returns the average of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(h.sum/h.count) * time.Microsecond
+}
+
+// Quantile returns the value below which the fraction q of the recorded
+// values fall, e.g. Quantile(0.99) for the p99. Like HdrHistogram it reports
+// the highest value equivalent to the bucket the quantile falls into.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	target := max(int64(math.Ceil(q*float64(h.count))), 1)
+	var seen int64
+	for _, idx := range h.indices() {
+		seen += h.counts[idx]
+		if seen >= target {
+			_, highest := bucketRange(idx)
+			return time.Duration(min(highest, h.max)) * time.Microsecond
+		}
+	}
+	return h.Max()
+}
+
+func (h *Histogram) indices() []int32 {
+	indices := make([]int32, 0, len(h.counts))
+	for idx := range h.counts {
+		indices = append(indices, idx)
+	}
+	slices.Sort(indices)
+	return indices
+}
+
+// histogramJSON is the serialized form of a Histogram. Buckets holds pairs of
+// bucket index and count for the non-empty buckets.
+type histogramJSON struct {
+	Count   int64      `json:"count"`
+	Sum     int64      `json:"sum_us"`
+	Min     int64      `json:"min_us"`
+	Max     int64      `json:"max_us"`
+	Buckets [][2]int64 `json:"buckets"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	out := histogramJSON{Count: h.count, Sum: h.sum, Min: h.min, Max: h.max, Buckets: [][2]int64{}}
+	for _, idx := range h.indices() {
+		out.Buckets = append(out.Buckets, [2]int64{int64(idx), h.counts[idx]})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var in histogramJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*h = Histogram{counts: make(map[int32]int64, len(in.Buckets)), count: in.Count, sum: in.Sum, min: in.Min, max: in.Max}
+	for _, b := range in.Buckets {
+		h.counts[int32(b[0])] = b[1]
+	}
+	return nil
+}
+
+// bucketIndex returns the index of the bucket v (in microseconds) falls into.
+func bucketIndex(v int64) int32 {
+	bucket := bits.Len64(uint64(v)|subBucketMask) - subBucketBits
+	sub := v >> bucket
+	return int32((bucket+1)<<subBucketHalfBits + int(sub) - subBucketHalf)
+}
+
+// bucketRange returns the lowest and highest value (in microseconds) that
+// fall into the bucket with the given index.
+func bucketRange(idx int32) (int64, int64) {
+	bucket := int(idx>>subBucketHalfBits) - 1
+	sub := int64(idx&(subBucketHalf-1)) + subBucketHalf
+	if bucket < 0 {
+		bucket = 0
+		sub -= subBucketHalf
+	}
+	lowest := sub << bucket
+	return lowest, lowest + (1 << bucket) - 1
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBucketIndex(t *testing.T) {
+	// Every value must fall into a bucket whose range contains it, and the
+	// bucket width must stay within three significant digits.
+	for _, v := range []int64{0, 1, 1023, 1024, 2047, 2048, 2049, 4095, 4096, 123456, 1 << 30, 1<<40 + 12345} {
+		idx := bucketIndex(v)
+		low, high := bucketRange(idx)
+		if v < low || v > high {
+			t.Errorf("value %d: bucket %d covers [%d, %d]", v, idx, low, high)
+		}
+		if width := high - low + 1; float64(width) > math.Max(1, float64(v)/1000) {
+			t.Errorf("value %d: bucket width %d is too coarse", v, width)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Microsecond)
+	}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 5000 * time.Microsecond},
+		{0.9, 9000 * time.Microsecond},
+		{0.99, 9900 * time.Microsecond},
+		{1, 10000 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		if diff := math.Abs(float64(got - tt.want)); diff > float64(tt.want)/1000 {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if h.Count() != 10000 {
+		t.Errorf("Count() = %d, want 10000", h.Count())
+	}
+	if h.Min() != time.Microsecond || h.Max() != 10*time.Millisecond {
+		t.Errorf("Min(), Max() = %v, %v", h.Min(), h.Max())
+	}
+	if NewHistogram().Quantile(0.5) != 0 {
+		t.Error("expected empty histogram quantile to be 0")
+	}
+}
+
+func TestHistogramMergeAndJSON(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(3 * time.Millisecond)
+	b.Record(5 * time.Millisecond)
+	a.Merge(b)
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewHistogram()
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if p50 := got.Quantile(0.5); got.Count() != 3 || got.Mean() != 3*time.Millisecond || p50 < 3*time.Millisecond || p50 > 3001*time.Microsecond {
+		t.Errorf("round-tripped histogram: count=%d mean=%v p50=%v", got.Count(), got.Mean(), got.Quantile(0.5))
+	}
+}
+
+func TestLatencyRecordWindows(t *testing.T) {
+	start := time.Unix(0, 0)
+	l := NewLatencyRecord()
+	l.Record(start, &Request{Scheduled: start.Add(100 * time.Millisecond), Duration: time.Millisecond})
+	l.Record(start, &Request{Scheduled: start.Add(2500 * time.Millisecond), Duration: time.Millisecond, Error: "boom"})
+	if len(l.Windows) != 3 {
+		t.Fatalf("expected 3 windows, got %d", len(l.Windows))
+	}
+	if l.Windows[0].Service.Count() != 1 || l.Windows[1].Service.Count() != 0 || l.Windows[2].Errors != 1 {
+		t.Errorf("unexpected windows: %+v %+v %+v", l.Windows[0], l.Windows[1], l.Windows[2])
+	}
+	if l.Service.Count() != 2 || l.Errors != 1 {
+		t.Errorf("unexpected totals: count=%d errors=%d", l.Service.Count(), l.Errors)
+	}
+}
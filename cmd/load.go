@@ -2,137 +2,276 @@
 package cmd
 
 import (
-	"bytes"
 	"cmp"
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"fosdem2026/cmd/stats"
+
 	"golang.org/x/sync/errgroup"
 )
 
 // Config holds load generation parameters.
 type Config struct {
-	Client      *http.Client
-	Log         *slog.Logger
-	URL         string
-	RPS         int
-	Clients     int
-	Duration    float64
-	Endpoints   int
-	ExpectError bool
+	Client *http.Client
+	Log    *slog.Logger
+	// URL is the base URL of the application, without a path.
+	URL      string
+	RPS      int
+	Clients  int
+	Duration float64
+	// Endpoints is the request mix. Requests are spread across the endpoints
+	// according to their weights.
+	Endpoints []Endpoint
+	// HistogramsOnly discards individual requests and only keeps their
+	// latency histograms, which bounds memory use for long, high-rate runs.
+	HistogramsOnly bool
+	// Stages replaces the constant RPS with a load profile. Duration is
+	// ignored in favor of the total duration of the stages.
+	Stages []Stage
+	// WarmUp is how long to send load before the measured load starts, in
+	// seconds, see WarmUp.
+	WarmUp float64
+}
+
+// LoadResult holds the outcome of Generate.
+type LoadResult struct {
+	// Requests is empty if Config.HistogramsOnly is set.
+	Requests []Request
+	Latency  *LatencyRecord
+	// Stages holds the latency per stage of the load profile, if any.
+	Stages []*StageResult
+	// Endpoints holds the latency per endpoint of the request mix.
+	Endpoints []*EndpointResult
+	// WarmUp holds the requests sent during the warm-up, if any. They are
+	// not part of any of the above.
+	WarmUp *WarmUpResult
+}
+
+// WarmUpResult holds the requests sent during the warm-up period, which are
+// kept apart from the measurements.
+type WarmUpResult struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Requests is empty if Config.HistogramsOnly is set.
+	Requests []Request      `json:"requests,omitempty"`
+	Latency  *LatencyRecord `json:"latency"`
+	// Usage is the resource usage of the app during the warm-up.
+	Usage *stats.Stats `json:"usage,omitempty"`
+}
+
+// Latency holds the service and response time histograms of a set of
+// requests and how many of them failed.
+type Latency struct {
+	Service  *Histogram `json:"service"`
+	Response *Histogram `json:"response"`
+	Errors   int64      `json:"errors"`
+}
+
+// NewLatency returns an empty Latency.
+func NewLatency() Latency {
+	return Latency{Service: NewHistogram(), Response: NewHistogram()}
+}
+
+// Record adds a request to the histograms.
+func (l *Latency) Record(req *Request) {
+	l.Service.Record(req.Duration)
+	l.Response.Record(req.ResponseTime)
+	if req.Error != "" {
+		l.Errors++
+	}
+}
+
+// Merge adds the requests recorded in o to l.
+func (l *Latency) Merge(o *Latency) {
+	l.Service.Merge(o.Service)
+	l.Response.Merge(o.Response)
+	l.Errors += o.Errors
+}
+
+// LatencyRecord holds latency histograms for a load run, in total and per
+// second of load.
+type LatencyRecord struct {
+	Latency
+	// Windows holds one entry per second, by scheduled send time.
+	Windows []*LatencyWindow `json:"windows"`
+}
+
+// LatencyWindow holds the latency histograms of the requests scheduled
+// within one second of load.
+type LatencyWindow struct {
+	Start time.Time `json:"start"`
+	Latency
+}
+
+// NewLatencyRecord returns an empty LatencyRecord.
+func NewLatencyRecord() *LatencyRecord {
+	return &LatencyRecord{Latency: NewLatency()}
+}
+
+// Record adds a request to the total and to the window of the second
+// (relative to start) in which it was scheduled.
+func (l *LatencyRecord) Record(start time.Time, req *Request) {
+	second := max(int(req.Scheduled.Sub(start)/time.Second), 0)
+	for len(l.Windows) <= second {
+		l.Windows = append(l.Windows, &LatencyWindow{
+			Start:   start.Add(time.Duration(len(l.Windows)) * time.Second),
+			Latency: NewLatency(),
+		})
+	}
+	l.Latency.Record(req)
+	l.Windows[second].Record(req)
 }
 
-// Generate creates HTTP load against the configured URL.
-func Generate(ctx context.Context, config *Config) (requests []Request, err error) {
-	if config.Clients > 0 && config.RPS > 0 {
+// Generate creates HTTP load against the configured URL. Failed requests are
+// counted in the result; only an invalid config or ctx ending fail it.
+func Generate(ctx context.Context, config *Config) (*LoadResult, error) {
+	if config.Clients > 0 && (config.RPS > 0 || len(config.Stages) > 0) {
 		return nil, fmt.Errorf("clients and rps cannot be set at the same time")
 	}
 
+	mix := normalizeMix(config.Endpoints)
+	if err := validateMix(mix); err != nil {
+		return nil, err
+	}
+	schedule := mixSchedule(mix)
+
 	var mu sync.Mutex
-	requestFn := func(ctx context.Context, i int) error {
-		start := time.Now()
-		req := Request{}
-		err := doRequest(ctx, config.Client, config.URL, config.ExpectError)
+	result := &LoadResult{Latency: NewLatencyRecord()}
+	if config.WarmUp > 0 {
+		var err error
+		result.WarmUp, err = WarmUp(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range mix {
+		result.Endpoints = append(result.Endpoints, &EndpointResult{Endpoint: e, Latency: NewLatency()})
+	}
+	start := time.Now()
+	stageStart := start
+	for _, stage := range config.Stages {
+		result.Stages = append(result.Stages, &StageResult{
+			Stage:   stage,
+			Start:   stageStart,
+			Latency: NewLatency(),
+		})
+		stageStart = stageStart.Add(time.Duration(stage.Duration * float64(time.Second)))
+	}
+	requestFn := func(ctx context.Context, i int, scheduled time.Time) error {
+		endpoint := schedule[i%len(schedule)]
+		sent := time.Now()
+		req := Request{Scheduled: scheduled, Endpoint: mix[endpoint].Name}
+		status, err := doRequest(ctx, config.Client, config.URL, &mix[endpoint])
 		if err != nil {
 			req.Error = err.Error()
 		}
+		req.Status = status
 		req.End = time.Now()
-		req.Duration = req.End.Sub(start)
+		req.Duration = req.End.Sub(sent)
+		req.ResponseTime = req.End.Sub(scheduled)
 		mu.Lock()
-		requests = append(requests, req)
+		result.Latency.Record(start, &req)
+		result.Endpoints[endpoint].Record(&req)
+		if len(result.Stages) > 0 {
+			result.Stages[stageIndex(config.Stages, scheduled.Sub(start))].Record(&req)
+		}
+		if !config.HistogramsOnly {
+			result.Requests = append(result.Requests, req)
+		}
 		mu.Unlock()
-		return err
+		// A failed request is a result like any other, so it doesn't stop
+		// the load.
+		return nil
 	}
 
+	var err error
 	duration := time.Duration(config.Duration * 1e9)
 	if config.Clients > 0 {
 		config.Log.Info("⌛ load starting (closed loop)", "clients", config.Clients, "duration", config.Duration, "url", config.URL)
 		err = ClosedLoop(ctx, config.Clients, duration, requestFn)
+	} else if len(config.Stages) > 0 {
+		config.Log.Info("⌛ load starting (open loop profile)", "stages", len(config.Stages), "duration", profileDuration(config.Stages), "url", config.URL)
+		err = OpenLoopProfile(ctx, start, config.Stages, requestFn)
 	} else {
 		config.Log.Info("⌛ load starting (open loop)", "requests", int(float64(config.RPS)*config.Duration), "duration", config.Duration, "url", config.URL)
 		err = OpenLoop(ctx, config.RPS, duration, requestFn)
 	}
+	latency := result.Latency
 	if err != nil {
-		config.Log.Error("load done", "requests", len(requests), "errors", countErrors(requests), "first_error", err)
+		config.Log.Error("load done", "requests", latency.Service.Count(), "errors", latency.Errors, "error", err)
 	} else {
-		config.Log.Info("✅ load done", "requests", len(requests), "errors", 0)
+		config.Log.Info("✅ load done", "requests", latency.Service.Count(), "errors", latency.Errors,
+			"p99_service", latency.Service.Quantile(0.99), "p99_response", latency.Response.Quantile(0.99))
 	}
-	return requests, err
+	return result, err
 }
 
-func doRequest(ctx context.Context, client *http.Client, url string, expectError bool) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return err
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = resp.Body.Close() }()
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	if expectError {
-		if resp.StatusCode < 500 {
-			return fmt.Errorf("expected error response: status=%d body=%s", resp.StatusCode, string(data))
-		}
-		return nil
+// WarmUp sends load for config.WarmUp seconds at the rate the measured load
+// starts with, so that one-off costs such as page faults, eBPF map
+// allocation, hook installation or exporter start-up don't end up in the
+// measurements. Failed requests don't fail the warm-up, absorbing them is
+// what it is for.
+func WarmUp(ctx context.Context, config *Config) (*WarmUpResult, error) {
+	c := *config
+	c.WarmUp = 0
+	c.Duration = config.WarmUp
+	c.Stages = nil
+	if len(config.Stages) > 0 {
+		c.RPS = max(int(config.Stages[0].StartRPS), 1)
 	}
-	want := []byte("Hello World\n")
-	if !bytes.Equal(data, want) {
-		return fmt.Errorf("invalid response: got=%s, want=%s", string(data), string(want))
+	c.Log = config.Log.With("phase", "warm-up")
+	start := time.Now()
+	load, err := Generate(ctx, &c)
+	if load == nil {
+		return nil, err
 	}
-	return nil
-}
-
-func countErrors(requests []Request) int {
-	var errors int
-	for _, request := range requests {
-		if request.Error != "" {
-			errors++
-		}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
-	return errors
+	return &WarmUpResult{Start: start, End: time.Now(), Requests: load.Requests, Latency: load.Latency}, nil
 }
 
 // OpenLoop schedules fn calls at a fixed rate (rps) for the given duration.
 // Each call runs in its own goroutine, so slow calls don't affect the schedule.
+// fn receives the time at which the call was scheduled, which may be earlier
+// than the time it actually starts if the runner falls behind; measuring from
+// it avoids coordinated omission.
 // Context cancellation stops scheduling new calls but does not cancel in-flight ones.
-func OpenLoop(ctx context.Context, rps int, duration time.Duration, fn func(context.Context, int) error) error {
+func OpenLoop(ctx context.Context, rps int, duration time.Duration, fn func(context.Context, int, time.Time) error) error {
 	n := int(float64(rps) * duration.Seconds())
 	var eg errgroup.Group
 	var ctxErr error
 	start := time.Now()
 loop:
 	for i := range n {
+		scheduled := start.Add(time.Duration(i+1) * (time.Second / time.Duration(rps)))
 		select {
 		case <-ctx.Done():
 			ctxErr = ctx.Err()
 			break loop
-		case <-time.After(time.Until(start.Add(time.Duration(i+1) * (time.Second / time.Duration(rps))))):
+		case <-time.After(time.Until(scheduled)):
 		}
 		eg.Go(func() error {
-			return fn(ctx, i)
+			return fn(ctx, i, scheduled)
 		})
 	}
 	return cmp.Or(eg.Wait(), ctxErr)
 }
 
 // ClosedLoop runs fn sequentially in each of the worker goroutines for the given duration.
-// The rate is determined by how fast fn completes (closed-loop control).
-// Once the duration elapses, the context passed to fn is canceled.
-func ClosedLoop(ctx context.Context, workers int, duration time.Duration, fn func(context.Context, int) error) error {
+// The rate is determined by how fast fn completes (closed-loop control), so
+// each call is scheduled at the time it starts.
+// Once the duration elapses, no new calls are started, while the ones in
+// flight complete with ctx so that they aren't counted as failures.
+func ClosedLoop(ctx context.Context, workers int, duration time.Duration, fn func(context.Context, int, time.Time) error) error {
 	var eg errgroup.Group
-	deadline := time.Now().Add(duration)
-	deadlineCtx, cancel := context.WithDeadline(ctx, deadline)
+	deadlineCtx, cancel := context.WithTimeout(ctx, duration)
 	defer cancel()
 
 	var i atomic.Int64
@@ -142,10 +281,10 @@ func ClosedLoop(ctx context.Context, workers int, duration time.Duration, fn fun
 			for {
 				select {
 				case <-deadlineCtx.Done():
-					return firstErr
+					return cmp.Or(firstErr, ctx.Err())
 				default:
 				}
-				firstErr = cmp.Or(firstErr, fn(deadlineCtx, int(i.Add(1)-1)))
+				firstErr = cmp.Or(firstErr, fn(ctx, int(i.Add(1)-1), time.Now()))
 			}
 		})
 	}
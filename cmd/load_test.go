@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestOpenLoopSchedule(t *testing.T) {
+	var mu sync.Mutex
+	scheduled := map[int]time.Time{}
+	err := OpenLoop(context.Background(), 100, 100*time.Millisecond, func(_ context.Context, i int, at time.Time) error {
+		mu.Lock()
+		scheduled[i] = at
+		mu.Unlock()
+		// A slow call must not delay the schedule of the following ones.
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 10 {
+		t.Fatalf("expected 10 calls, got %d", len(scheduled))
+	}
+	for i := 1; i < len(scheduled); i++ {
+		if gap := scheduled[i].Sub(scheduled[i-1]); gap != 10*time.Millisecond {
+			t.Errorf("call %d scheduled %v after the previous one, want 10ms", i, gap)
+		}
+	}
+}
+
+func TestClosedLoop(t *testing.T) {
+	var calls atomic.Int64
+	err := ClosedLoop(context.Background(), 4, 100*time.Millisecond, func(ctx context.Context, _ int, _ time.Time) error {
+		calls.Add(1)
+		// Calls in flight when the duration elapses must not be canceled.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(30 * time.Millisecond):
+			return nil
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n < 4 {
+		t.Errorf("got %d calls, want at least one per worker", n)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, "Hello World\n")
+	}))
+	defer srv.Close()
+
+	// Failed requests are counted rather than failing the load.
+	result, err := Generate(context.Background(), &Config{
+		Client:    srv.Client(),
+		Log:       slog.New(slog.DiscardHandler),
+		URL:       srv.URL,
+		RPS:       100,
+		Duration:  0.2,
+		Endpoints: []Endpoint{{Path: "/load", ExpectBody: "Hello World\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Latency.Errors != 10 {
+		t.Errorf("got %d errors, want 10", result.Latency.Errors)
+	}
+	var failed int
+	for _, req := range result.Requests {
+		if req.Error != "" {
+			failed++
+		}
+	}
+	if failed != 10 {
+		t.Errorf("got %d failed requests, want 10", failed)
+	}
+}
+
+func TestGenerateWarmUp(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// The first requests fail, as if the app was still being attached to.
+		if calls.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "Hello World\n")
+	}))
+	defer srv.Close()
+
+	result, err := Generate(context.Background(), &Config{
+		Client:    srv.Client(),
+		Log:       slog.New(slog.DiscardHandler),
+		URL:       srv.URL,
+		RPS:       100,
+		Duration:  0.2,
+		WarmUp:    0.1,
+		Endpoints: []Endpoint{{Path: "/load", ExpectBody: "Hello World\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WarmUp == nil {
+		t.Fatal("no warm-up result")
+	}
+	if n := result.WarmUp.Latency.Service.Count(); n != 10 {
+		t.Errorf("got %d warm-up requests, want 10", n)
+	}
+	if result.WarmUp.Latency.Errors != 3 {
+		t.Errorf("got %d warm-up errors, want 3", result.WarmUp.Latency.Errors)
+	}
+	if n := result.Latency.Service.Count(); n != 20 || len(result.Requests) != 20 {
+		t.Errorf("got %d measured requests (%d recorded), want 20", n, len(result.Requests))
+	}
+	if result.Latency.Errors != 0 {
+		t.Errorf("got %d measured errors, want 0", result.Latency.Errors)
+	}
+	for _, req := range result.Requests {
+		if req.Scheduled.Before(result.WarmUp.End) {
+			t.Errorf("measured request scheduled at %v, before the warm-up ended at %v", req.Scheduled, result.WarmUp.End)
+		}
+	}
+}
+
+func TestSplitStats(t *testing.T) {
+	start := time.Now()
+	stats := []*container.StatsResponse{}
+	for i := range 5 {
+		stats = append(stats, &container.StatsResponse{Read: start.Add(time.Duration(i) * time.Second)})
+	}
+	before, after := splitStats(stats, start.Add(2*time.Second))
+	if len(before) != 2 || len(after) != 3 {
+		t.Errorf("got %d and %d snapshots, want 2 and 3", len(before), len(after))
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+var (
+	// errorLine and warningLine classify log lines. Besides the usual levels,
+	// the exporters log unknown bpftrace events without one.
+	errorLine   = regexp.MustCompile(`(?i)\b(error|fatal|panic|exception|traceback)\b`)
+	warningLine = regexp.MustCompile(`(?i)\bwarn(ing)?\b|unknown event type`)
+)
+
+// logCapture streams the logs of the containers of a run into files.
+type logCapture struct {
+	pending  []*LogPayload
+	payloads chan *LogPayload
+}
+
+// captureLogs streams the stdout and stderr of each container into
+// dir/<container>.log until the container stops or ctx is done.
+func captureLogs(ctx context.Context, containers []string, dir string) *logCapture {
+	l := &logCapture{payloads: make(chan *LogPayload, len(containers))}
+	for _, name := range containers {
+		p := &LogPayload{Container: name, File: filepath.Join(dir, name+".log")}
+		l.pending = append(l.pending, p)
+		go func() {
+			// Copy the payload so that Wait doesn't race with a stream
+			// that doesn't end in time.
+			p := *p
+			if err := streamLogs(ctx, &p); err != nil {
+				p.Error = err.Error()
+			}
+			l.payloads <- &p
+		}()
+	}
+	return l
+}
+
+// streamLogs writes the logs of p.Container to p.File and counts them.
+func streamLogs(ctx context.Context, p *LogPayload) error {
+	if err := os.MkdirAll(filepath.Dir(p.File), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p.File)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	logs, err := dockerClient.ContainerLogs(ctx, p.Container, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = logs.Close() }()
+	counter := &logCounter{w: f, p: p}
+	_, err = stdcopy.StdCopy(counter, counter, logs)
+	counter.flush()
+	return err
+}
+
+// Wait waits up to timeout for the log streams to end, which they do once
+// their container stopped, and returns what they captured in the order of
+// the containers.
+func (l *logCapture) Wait(timeout time.Duration) []*LogPayload {
+	done := map[string]*LogPayload{}
+	deadline := time.After(timeout)
+wait:
+	for range l.pending {
+		select {
+		case p := <-l.payloads:
+			done[p.Container] = p
+		case <-deadline:
+			break wait
+		}
+	}
+	// Take the streams that ended just as the deadline passed, too.
+drain:
+	for len(done) < len(l.pending) {
+		select {
+		case p := <-l.payloads:
+			done[p.Container] = p
+		default:
+			break drain
+		}
+	}
+	payloads := make([]*LogPayload, 0, len(l.pending))
+	for _, p := range l.pending {
+		if d, ok := done[p.Container]; ok {
+			p = d
+		} else {
+			p.Error = "log stream did not end"
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads
+}
+
+// logCounter counts the lines written through it by severity.
+type logCounter struct {
+	w    io.Writer
+	p    *LogPayload
+	line []byte
+}
+
+func (c *logCounter) Write(data []byte) (int, error) {
+	n, err := c.w.Write(data)
+	c.p.Bytes += int64(n)
+	c.line = append(c.line, data[:n]...)
+	for {
+		i := bytes.IndexByte(c.line, '\n')
+		if i < 0 {
+			break
+		}
+		c.count(c.line[:i])
+		c.line = c.line[i+1:]
+	}
+	return n, err
+}
+
+// flush counts the last line if it isn't terminated.
+func (c *logCounter) flush() {
+	if len(c.line) > 0 {
+		c.count(c.line)
+		c.line = nil
+	}
+}
+
+func (c *logCounter) count(line []byte) {
+	c.p.Count++
+	switch {
+	case errorLine.Match(line):
+		c.p.Errors++
+	case warningLine.Match(line):
+		c.p.Warnings++
+	}
+}
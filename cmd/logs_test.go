@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestLogCounter(t *testing.T) {
+	var stream bytes.Buffer
+	stdout := stdcopy.NewStdWriter(&stream, stdcopy.Stdout)
+	stderr := stdcopy.NewStdWriter(&stream, stdcopy.Stderr)
+	_, _ = stdout.Write([]byte("Starting server on :8080...\n[Bridge] WARNING: Could not connect"))
+	_, _ = stdout.Write([]byte(" to collector after 30 attempts\n"))
+	_, _ = stderr.Write([]byte("Unknown event type: gc_start\nError shutting down OTel provider: context deadline exceeded\n"))
+	_, _ = stdout.Write([]byte("Received signal 15 (terminated), shutting down..."))
+
+	var out bytes.Buffer
+	p := &LogPayload{}
+	counter := &logCounter{w: &out, p: p}
+	if _, err := stdcopy.StdCopy(counter, counter, &stream); err != nil {
+		t.Fatal(err)
+	}
+	counter.flush()
+
+	want := LogPayload{Count: 5, Bytes: int64(out.Len()), Warnings: 2, Errors: 1}
+	if *p != want {
+		t.Errorf("got %+v, want %+v", *p, want)
+	}
+}
+
+func TestLogCaptureWait(t *testing.T) {
+	l := &logCapture{
+		pending:  []*LogPayload{{Container: "manual"}, {Container: "go-auto"}},
+		payloads: make(chan *LogPayload, 2),
+	}
+	l.payloads <- &LogPayload{Container: "go-auto", Count: 3}
+
+	payloads := l.Wait(0)
+	if len(payloads) != 2 || payloads[0].Container != "manual" || payloads[1].Container != "go-auto" {
+		t.Fatalf("unexpected payloads: %+v", payloads)
+	}
+	if payloads[0].Error == "" {
+		t.Error("expected an error for the stream that did not end")
+	}
+	if payloads[1].Count != 3 || payloads[1].Error != "" {
+		t.Errorf("go-auto = %+v", payloads[1])
+	}
+}
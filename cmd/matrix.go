@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// Cell is one combination of the dimensions of an experiment matrix.
+type Cell struct {
+	Scenario string `json:"scenario"`
+	// RuntimeVersion is empty for scenarios that pin their own Go version.
+	RuntimeVersion string `json:"runtime_version,omitempty"`
+	Workers        int    `json:"workers"`
+	Archetype      string `json:"archetype"`
+}
+
+// String returns a label for the cell, e.g. "manual/go1.25.5/w4/throughput".
+func (c *Cell) String() string {
+	parts := []string{c.Scenario}
+	if c.RuntimeVersion != "" {
+		parts = append(parts, "go"+c.RuntimeVersion)
+	}
+	parts = append(parts, fmt.Sprintf("w%d", c.Workers), c.Archetype)
+	return strings.Join(parts, "/")
+}
+
+// resultLabel returns what results are grouped by in reports: their cell if
+// they come from a matrix, or else their scenario.
+func resultLabel(r *TestResult) string {
+	if r.Cell != nil {
+		return r.Cell.String()
+	}
+	if r.Scenario == "" {
+		return "unknown"
+	}
+	return r.Scenario
+}
+
+// expandMatrix returns the cartesian product of the dimensions. A zero
+// Workers leaves the archetype's. Scenarios that pin their own Go version
+// get a single cell per workers and archetype instead of one per runtime
+// version, since the runtime version does not change their build.
+func expandMatrix(scenarios []Scenario, versions []string, workers []int, archetypes []string) []*Cell {
+	if len(workers) == 0 {
+		workers = []int{0}
+	}
+	cells := []*Cell{}
+	for _, s := range scenarios {
+		scenarioVersions := versions
+		if s.OwnGoVersion() {
+			scenarioVersions = []string{""}
+		}
+		for _, v := range scenarioVersions {
+			for _, w := range workers {
+				for _, a := range archetypes {
+					cells = append(cells, &Cell{Scenario: s.Name(), RuntimeVersion: v, Workers: w, Archetype: a})
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// CmdMatrix is the CLI command for running an experiment matrix.
+var CmdMatrix = &cli.Command{
+	Name:    "matrix",
+	Aliases: []string{"m"},
+	Usage:   "runs every combination of scenarios, Go versions, workers and archetypes",
+	Description: `
+	Run the cartesian product of the given scenarios, runtime versions, workers
+	(which also sets GOMAXPROCS of the app) and archetypes, each cell --num times.
+
+	The runs of all cells are shuffled by default (--order random) so that drift over
+	the course of the matrix, e.g. thermal throttling, does not correlate with any one
+	cell. Pass the --seed logged at the start to repeat the same order.
+
+	Each result is tagged with its cell, which report groups results by.
+	`,
+	Flags: slices.Concat([]cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "scenario",
+			Usage: "The scenarios to run, or all",
+			Value: []string{"all"},
+		},
+		&cli.StringSliceFlag{
+			Name:  "runtime-version",
+			Usage: "The Go versions to build the app with",
+			Value: []string{"1.25.5"},
+		},
+		&cli.IntSliceFlag{
+			Name:  "workers",
+			Usage: "The numbers of workers of the app, the archetype's if not set",
+		},
+		&cli.StringSliceFlag{
+			Name:    "archetype",
+			Aliases: []string{"a"},
+			Usage:   "The workload archetypes to run (" + strings.Join(Archetypes(), ", ") + ")",
+			Value:   []string{"idle"},
+		},
+	}, runFlags, workloadFlags),
+	Action: func(ctx context.Context, c *cli.Command) error {
+		log, cancel := NewLogger(ctx)
+		defer cancel(nil)
+		if err := LoadScenarios(c.String("scenarios")); err != nil {
+			return err
+		}
+
+		names := c.StringSlice("scenario")
+		if slices.Contains(names, "all") {
+			names = scenarioNames()
+		}
+		selected := make([]Scenario, 0, len(names))
+		for _, name := range names {
+			s, err := lookupScenario(name)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, s)
+		}
+
+		jobs := []*job{}
+		for _, cell := range expandMatrix(selected, c.StringSlice("runtime-version"), c.IntSlice("workers"), c.StringSlice("archetype")) {
+			inputs, err := NewInput(cell.Archetype)
+			if err != nil {
+				return err
+			}
+			if err := applyWorkloadFlags(c, inputs); err != nil {
+				return err
+			}
+			inputs.RuntimeVersion = cell.RuntimeVersion
+			if cell.Workers > 0 {
+				inputs.Workers = cell.Workers
+			}
+			cell.Workers = inputs.Workers
+			if err := inputs.Validate(); err != nil {
+				return fmt.Errorf("invalid inputs for %s: %w", cell, err)
+			}
+			jobs = append(jobs, &job{scenario: scenarios[cell.Scenario], inputs: inputs, cell: cell})
+		}
+
+		opts, err := newRunManyOpts(c, log, OrderRandom)
+		if err != nil {
+			return err
+		}
+		log.Info("Running matrix", "cells", len(jobs), "runs", len(jobs)*opts.Num, "order", opts.Order)
+
+		results, err := runJobs(ctx, opts, jobs)
+		if err != nil {
+			return err
+		}
+		return outputResults(c, results)
+	},
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func TestExpandMatrix(t *testing.T) {
+	def, err := lookupScenario("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usdt, err := lookupScenario("usdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cells := expandMatrix([]Scenario{def, usdt}, []string{"1.24.0", "1.25.5"}, []int{1, 4}, []string{"idle", "throughput"})
+	// default gets every runtime version, usdt pins its own.
+	if got, want := len(cells), 2*2*2+2*2; got != want {
+		t.Fatalf("got %d cells, want %d", got, want)
+	}
+	seen := map[string]bool{}
+	for _, c := range cells {
+		if seen[c.String()] {
+			t.Errorf("duplicate cell %s", c)
+		}
+		seen[c.String()] = true
+		if c.Scenario == "usdt" && c.RuntimeVersion != "" {
+			t.Errorf("cell %s has a runtime version", c)
+		}
+	}
+	for _, label := range []string{"default/go1.24.0/w1/idle", "default/go1.25.5/w4/throughput", "usdt/w4/idle"} {
+		if !seen[label] {
+			t.Errorf("missing cell %s", label)
+		}
+	}
+
+	cells = expandMatrix([]Scenario{def}, []string{"1.25.5"}, nil, []string{"idle"})
+	if len(cells) != 1 || cells[0].Workers != 0 {
+		t.Errorf("without workers got %v, want a single cell with the archetype's workers", cells)
+	}
+}
+
+func TestResultLabel(t *testing.T) {
+	for _, tt := range []struct {
+		r    *TestResult
+		want string
+	}{
+		{&TestResult{}, "unknown"},
+		{&TestResult{Scenario: "manual"}, "manual"},
+		{&TestResult{Scenario: "manual", Cell: &Cell{Scenario: "manual", RuntimeVersion: "1.25.5", Workers: 2, Archetype: "latency"}}, "manual/go1.25.5/w2/latency"},
+	} {
+		if got := resultLabel(tt.r); got != tt.want {
+			t.Errorf("resultLabel() = %q, want %q", got, tt.want)
+		}
+	}
+}
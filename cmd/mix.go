@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Endpoint is one kind of request in a request mix.
+type Endpoint struct {
+	// Name identifies the endpoint in results. It defaults to the method and
+	// path, e.g. "GET /load".
+	Name   string `json:"name,omitempty"`
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+	// Weight is the share of requests sent to this endpoint relative to the
+	// other endpoints of the mix. It defaults to 1 if unset, while 0 leaves
+	// the endpoint out.
+	Weight *int `json:"weight,omitempty"`
+	// ExpectStatus is the status code a successful request returns. It
+	// defaults to 200.
+	ExpectStatus int `json:"expect_status,omitempty"`
+	// ExpectServerError makes any status of 500 or above a success instead of
+	// ExpectStatus, e.g. for a sidecar that rewrites the status of failing
+	// requests.
+	ExpectServerError bool `json:"expect_server_error,omitempty"`
+	// ExpectBody is the body a successful request returns. The body is not
+	// checked if it is empty.
+	ExpectBody string `json:"expect_body,omitempty"`
+}
+
+// weight returns the weight of the endpoint, or 1 if it is unset.
+func (e *Endpoint) weight() int {
+	if e.Weight == nil {
+		return 1
+	}
+	return *e.Weight
+}
+
+// EndpointResult holds the latency of the requests sent to one endpoint of
+// the request mix.
+type EndpointResult struct {
+	Endpoint
+	Latency
+}
+
+// defaultMix returns the request mix used if none is given: every request
+// goes to the load handler, which fails with a server error if Exceptions is
+// set.
+func defaultMix(inputs *Input) []Endpoint {
+	e := Endpoint{Name: "load", Path: "/load", ExpectBody: "Hello World\n"}
+	if inputs.Exceptions {
+		e.ExpectServerError, e.ExpectBody = true, ""
+	}
+	return []Endpoint{e}
+}
+
+// LoadMix reads a request mix from a JSON file holding an array of
+// endpoints.
+func LoadMix(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mix []Endpoint
+	if err := json.Unmarshal(data, &mix); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	mix = normalizeMix(mix)
+	if err := validateMix(mix); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mix, nil
+}
+
+// normalizeMix returns a copy of the mix with defaults filled in.
+func normalizeMix(mix []Endpoint) []Endpoint {
+	out := make([]Endpoint, len(mix))
+	for i, e := range mix {
+		e.Method = cmp.Or(strings.ToUpper(e.Method), http.MethodGet)
+		e.Name = cmp.Or(e.Name, e.Method+" "+e.Path)
+		if e.Weight == nil {
+			weight := 1
+			e.Weight = &weight
+		}
+		e.ExpectStatus = cmp.Or(e.ExpectStatus, http.StatusOK)
+		out[i] = e
+	}
+	return out
+}
+
+func validateMix(mix []Endpoint) error {
+	if len(mix) == 0 {
+		return fmt.Errorf("request mix is empty")
+	}
+	names := map[string]bool{}
+	var total int
+	for _, e := range mix {
+		if !strings.HasPrefix(e.Path, "/") {
+			return fmt.Errorf("endpoint %q: path must start with /", e.Name)
+		}
+		if e.weight() < 0 {
+			return fmt.Errorf("endpoint %q: weight must not be negative", e.Name)
+		}
+		total += e.weight()
+		if names[e.Name] {
+			return fmt.Errorf("endpoint %q: duplicate name", e.Name)
+		}
+		names[e.Name] = true
+	}
+	if total == 0 {
+		return fmt.Errorf("request mix has no endpoint with a positive weight")
+	}
+	return nil
+}
+
+// mixSchedule returns the order in which the endpoints of the mix are
+// requested, repeating every len(schedule) requests. Each endpoint appears
+// Weight times, spread out using smooth weighted round-robin so that e.g.
+// weights 3 and 1 give A A B A rather than A A A B.
+func mixSchedule(mix []Endpoint) []int {
+	var total int
+	for _, e := range mix {
+		total += e.weight()
+	}
+	schedule := make([]int, 0, total)
+	current := make([]int, len(mix))
+	for range total {
+		best := -1
+		for i, e := range mix {
+			current[i] += e.weight()
+			if best < 0 || current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, best)
+	}
+	return schedule
+}
+
+// doRequest sends a request to the endpoint and checks the response against
+// its expectations. It returns the status code, or zero if no response was
+// received.
+func doRequest(ctx context.Context, client *http.Client, baseURL string, e *Endpoint) (int, error) {
+	var body io.Reader
+	if e.Body != "" {
+		body = strings.NewReader(e.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, e.Method, baseURL+e.Path, body)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if e.ExpectServerError {
+		if resp.StatusCode < http.StatusInternalServerError {
+			return resp.StatusCode, fmt.Errorf("expected error response: status=%d body=%s", resp.StatusCode, string(data))
+		}
+	} else if resp.StatusCode != e.ExpectStatus {
+		return resp.StatusCode, fmt.Errorf("unexpected status: got=%d, want=%d body=%s", resp.StatusCode, e.ExpectStatus, string(data))
+	}
+	if e.ExpectBody != "" && !bytes.Equal(data, []byte(e.ExpectBody)) {
+		return resp.StatusCode, fmt.Errorf("invalid response: got=%s, want=%s", string(data), e.ExpectBody)
+	}
+	return resp.StatusCode, nil
+}
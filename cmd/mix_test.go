@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func weight(n int) *int { return &n }
+
+func TestMixSchedule(t *testing.T) {
+	mix := normalizeMix([]Endpoint{{Path: "/a", Weight: weight(3)}, {Path: "/b"}, {Path: "/c", Weight: weight(0)}})
+	got := mixSchedule(mix)
+	want := []int{0, 0, 1, 0}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestLoadMixWeight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mix.json")
+	data := `[{"path": "/a"}, {"path": "/b", "weight": 0}]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mix, err := LoadMix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix[0].weight() != 1 || mix[1].weight() != 0 {
+		t.Errorf("got weights %d and %d, want 1 and 0", mix[0].weight(), mix[1].weight())
+	}
+}
+
+func TestGenerateMix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /load", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "Hello World\n")
+	})
+	mux.HandleFunc("POST /echo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	})
+	mux.HandleFunc("GET /fail", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("GET /proxy", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mix := []Endpoint{
+		{Path: "/load", Weight: weight(2), ExpectBody: "Hello World\n"},
+		{Name: "echo", Method: "post", Path: "/echo", Body: "ping", ExpectBody: "ping"},
+		{Path: "/fail", ExpectStatus: http.StatusInternalServerError},
+		{Path: "/proxy", ExpectServerError: true},
+	}
+	result, err := Generate(context.Background(), &Config{
+		Client:    srv.Client(),
+		Log:       slog.New(slog.DiscardHandler),
+		URL:       srv.URL,
+		RPS:       100,
+		Duration:  0.4,
+		Endpoints: mix,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int64{}
+	for _, e := range result.Endpoints {
+		counts[e.Name] = e.Service.Count()
+		if e.Errors != 0 {
+			t.Errorf("endpoint %s: %d errors", e.Name, e.Errors)
+		}
+	}
+	want := map[string]int64{"GET /load": 16, "echo": 8, "GET /fail": 8, "GET /proxy": 8}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("endpoint %s: got %d requests, want %d", name, counts[name], n)
+		}
+	}
+	for _, req := range result.Requests {
+		if req.Endpoint == "GET /fail" && req.Status != http.StatusInternalServerError {
+			t.Errorf("request to %s: got status %d", req.Endpoint, req.Status)
+		}
+	}
+}
+
+func TestValidateMix(t *testing.T) {
+	tests := []struct {
+		name string
+		mix  []Endpoint
+	}{
+		{"empty", nil},
+		{"relative path", []Endpoint{{Path: "load"}}},
+		{"negative weight", []Endpoint{{Path: "/load", Weight: weight(-1)}}},
+		{"zero weights", []Endpoint{{Path: "/load", Weight: weight(0)}}},
+		{"duplicate", []Endpoint{{Path: "/load"}, {Path: "/load"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMix(normalizeMix(tt.mix)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// Order is the order in which the runs of several scenarios are executed.
+type Order string
+
+const (
+	// OrderSequential runs all repetitions of a scenario before moving on to
+	// the next one.
+	OrderSequential Order = "sequential"
+	// OrderInterleaved runs the scenarios round-robin: the first repetition
+	// of each, then the second one of each and so on.
+	OrderInterleaved Order = "interleaved"
+	// OrderRandom shuffles the runs of all scenarios with a seed.
+	OrderRandom Order = "random"
+)
+
+// Orders lists the supported orders.
+var Orders = []Order{OrderSequential, OrderInterleaved, OrderRandom}
+
+// ParseOrder parses the name of an order.
+func ParseOrder(s string) (Order, error) {
+	if !slices.Contains(Orders, Order(s)) {
+		return "", fmt.Errorf("unknown order %q, expected sequential, interleaved or random", s)
+	}
+	return Order(s), nil
+}
+
+// pendingRun is a run of a job that has no cached result yet.
+type pendingRun struct {
+	job *job
+	run int
+}
+
+// orderRuns reorders runs, which are given job after job with their runs
+// ascending, according to order.
+func orderRuns(runs []pendingRun, order Order, seed uint64) {
+	switch order {
+	case OrderInterleaved:
+		slices.SortStableFunc(runs, func(a, b pendingRun) int { return a.run - b.run })
+	case OrderRandom:
+		rng := rand.New(rand.NewPCG(seed, seed))
+		rng.Shuffle(len(runs), func(i, j int) { runs[i], runs[j] = runs[j], runs[i] })
+	}
+}
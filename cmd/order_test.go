@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderRuns(t *testing.T) {
+	a, b := &job{}, &job{}
+	runs := func() []pendingRun {
+		return []pendingRun{{a, 0}, {a, 1}, {a, 2}, {b, 0}, {b, 1}, {b, 2}}
+	}
+
+	sequential := runs()
+	orderRuns(sequential, OrderSequential, 0)
+	if !slices.Equal(sequential, runs()) {
+		t.Errorf("sequential order changed the runs: %v", sequential)
+	}
+
+	interleaved := runs()
+	orderRuns(interleaved, OrderInterleaved, 0)
+	want := []pendingRun{{a, 0}, {b, 0}, {a, 1}, {b, 1}, {a, 2}, {b, 2}}
+	if !slices.Equal(interleaved, want) {
+		t.Errorf("interleaved order = %v, want %v", interleaved, want)
+	}
+
+	random := runs()
+	orderRuns(random, OrderRandom, 42)
+	again := runs()
+	orderRuns(again, OrderRandom, 42)
+	if !slices.Equal(random, again) {
+		t.Errorf("random order is not reproducible with the same seed: %v and %v", random, again)
+	}
+	if slices.Equal(random, runs()) {
+		t.Errorf("random order did not shuffle the runs")
+	}
+	sorted := slices.Clone(random)
+	slices.SortStableFunc(sorted, func(x, y pendingRun) int {
+		return cmpJob(x, y, a)
+	})
+	if !slices.Equal(sorted, runs()) {
+		t.Errorf("random order lost or duplicated runs: %v", random)
+	}
+}
+
+// cmpJob orders runs of first before others, and then by run.
+func cmpJob(x, y pendingRun, first *job) int {
+	if (x.job == first) != (y.job == first) {
+		if x.job == first {
+			return -1
+		}
+		return 1
+	}
+	return x.run - y.run
+}
+
+func TestParseOrder(t *testing.T) {
+	for _, o := range Orders {
+		got, err := ParseOrder(string(o))
+		if err != nil || got != o {
+			t.Errorf("ParseOrder(%q) = %q, %v", o, got, err)
+		}
+	}
+	if _, err := ParseOrder("alphabetical"); err == nil {
+		t.Error("ParseOrder(\"alphabetical\") succeeded")
+	}
+}
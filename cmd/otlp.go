@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"cmp"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// receiverAddr is where the runner receives the traces the collector
+	// forwards to it, see the otlphttp/runner exporter in
+	// infrastructure/otel-collector-config.yaml.
+	receiverAddr = ":4319"
+	// traceDrainTimeout bounds how long to wait after a run for the spans of
+	// its requests, which the app, sidecars and collector all batch.
+	traceDrainTimeout = 15 * time.Second
+)
+
+// traceReceiver is an OTLP/HTTP receiver counting the spans it receives per
+// service. It only accepts protobuf payloads, which is what the collector
+// sends.
+type traceReceiver struct {
+	srv *http.Server
+
+	mu       sync.Mutex
+	services map[string]*receivedTraces
+}
+
+// receivedTraces holds what a service exported.
+type receivedTraces struct {
+	spans int
+	bytes int64
+	// roots holds the root spans, one per trace.
+	roots []receivedSpan
+}
+
+// receivedSpan holds what the fidelity of a root span is checked on, see
+// validateFidelity.
+type receivedSpan struct {
+	Name   string
+	Method string
+	Path   string
+	Status int
+	Start  time.Time
+	End    time.Time
+}
+
+// newReceivedSpan reads a span, taking the HTTP attributes from either the
+// current semantic conventions or the ones before 1.21, which some
+// instrumentations still use.
+func newReceivedSpan(span *tracepb.Span) receivedSpan {
+	s := receivedSpan{
+		Name:  span.GetName(),
+		Start: time.Unix(0, int64(span.GetStartTimeUnixNano())),
+		End:   time.Unix(0, int64(span.GetEndTimeUnixNano())),
+	}
+	for _, attr := range span.GetAttributes() {
+		value := attr.GetValue()
+		switch attr.GetKey() {
+		case "http.request.method", "http.method":
+			s.Method = value.GetStringValue()
+		case "url.path":
+			s.Path = value.GetStringValue()
+		case "http.target":
+			s.Path = cmp.Or(s.Path, value.GetStringValue())
+		case "http.response.status_code", "http.status_code":
+			s.Status = int(value.GetIntValue())
+		}
+	}
+	s.Path, _, _ = strings.Cut(s.Path, "?")
+	return s
+}
+
+// startTraceReceiver listens on addr and serves the OTLP traces endpoint
+// until Stop is called.
+func startTraceReceiver(addr string) (*traceReceiver, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := &traceReceiver{services: map[string]*receivedTraces{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/traces", r.handleTraces)
+	r.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = r.srv.Serve(ln) }()
+	return r, nil
+}
+
+func (r *traceReceiver) handleTraces(w http.ResponseWriter, req *http.Request) {
+	if ct := req.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		http.Error(w, "unsupported content type "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	body := req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var export coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(data, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.record(&export)
+
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// record counts the spans of an export per service.
+func (r *traceReceiver) record(export *coltracepb.ExportTraceServiceRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range export.GetResourceSpans() {
+		service := "unknown"
+		for _, attr := range rs.GetResource().GetAttributes() {
+			if attr.GetKey() == "service.name" {
+				service = attr.GetValue().GetStringValue()
+			}
+		}
+		t, ok := r.services[service]
+		if !ok {
+			t = &receivedTraces{}
+			r.services[service] = t
+		}
+		t.bytes += int64(proto.Size(rs))
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				t.spans++
+				if len(span.GetParentSpanId()) > 0 {
+					continue
+				}
+				// Network level instrumentation also traces the runner
+				// fetching profiles, which is not part of the load.
+				if root := newReceivedSpan(span); !strings.HasPrefix(root.Path, "/debug/pprof/") {
+					t.roots = append(t.roots, root)
+				}
+			}
+		}
+	}
+}
+
+// traces returns what each service exported, sorted by service, with the
+// number of traces whose root span started within [from, to].
+func (r *traceReceiver) traces(from, to time.Time) []*TracesPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payloads := make([]*TracesPayload, 0, len(r.services))
+	for service, t := range r.services {
+		p := &TracesPayload{Service: service, Count: t.spans, Bytes: t.bytes}
+		for _, root := range t.roots {
+			if !root.Start.Before(from) && !root.Start.After(to) {
+				p.Traces++
+			}
+		}
+		payloads = append(payloads, p)
+	}
+	slices.SortFunc(payloads, func(a, b *TracesPayload) int {
+		return strings.Compare(a.Service, b.Service)
+	})
+	return payloads
+}
+
+// rootSpans returns the root spans of all services that started within
+// [from, to].
+func (r *traceReceiver) rootSpans(from, to time.Time) []receivedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := []receivedSpan{}
+	for _, t := range r.services {
+		for _, root := range t.roots {
+			if !root.Start.Before(from) && !root.Start.After(to) {
+				spans = append(spans, root)
+			}
+		}
+	}
+	return spans
+}
+
+// Wait waits until the services together exported at least want traces
+// whose root span started within [from, to], or until timeout.
+func (r *traceReceiver) Wait(ctx context.Context, want int, from, to time.Time, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		if tracesCount(r.traces(from, to)) >= want {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// Stop shuts the receiver down.
+func (r *traceReceiver) Stop(ctx context.Context) error {
+	if err := r.srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// tracesCount returns the number of traces exported by all services.
+func tracesCount(payloads []*TracesPayload) int {
+	n := 0
+	for _, p := range payloads {
+		n += p.Traces
+	}
+	return n
+}
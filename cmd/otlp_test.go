@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTraceReceiver(t *testing.T) {
+	start := time.Unix(100, 0)
+	span := func(at time.Duration, parent []byte) *tracepb.Span {
+		return &tracepb.Span{StartTimeUnixNano: uint64(start.Add(at).UnixNano()), ParentSpanId: parent}
+	}
+	resourceSpans := func(service string, spans ...*tracepb.Span) *tracepb.ResourceSpans {
+		return &tracepb.ResourceSpans{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+				Key:   "service.name",
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: service}},
+			}}},
+			ScopeSpans: []*tracepb.ScopeSpans{{Spans: spans}},
+		}
+	}
+	export := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{
+		// One trace before load, two during load with a child span each.
+		resourceSpans("manual",
+			span(-time.Second, nil),
+			span(time.Second, nil), span(time.Second, []byte{1}),
+			span(2*time.Second, nil), span(2*time.Second, []byte{2}),
+		),
+		resourceSpans("fosdem-ebpf", span(time.Second, nil)),
+	}}
+	data, err := proto.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	_, _ = gz.Write(data)
+	_ = gz.Close()
+
+	r := &traceReceiver{services: map[string]*receivedTraces{}}
+	req := httptest.NewRequest(http.MethodPost, "/v1/traces", &body)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.handleTraces(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	traces := r.traces(start, start.Add(3*time.Second))
+	if len(traces) != 2 || traces[0].Service != "fosdem-ebpf" || traces[1].Service != "manual" {
+		t.Fatalf("unexpected services: %+v", traces)
+	}
+	if manual := traces[1]; manual.Count != 5 || manual.Traces != 2 || manual.Bytes == 0 {
+		t.Errorf("manual = %+v, want 5 spans in 2 traces", manual)
+	}
+	if n := tracesCount(traces); n != 3 {
+		t.Errorf("tracesCount = %d, want 3", n)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewReader([]byte("{}")))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.handleTraces(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("json status = %d, want %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+}
+
+func TestNewReceivedSpan(t *testing.T) {
+	str := func(key, value string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+	}
+	code := func(key string, value int64) *commonpb.KeyValue {
+		return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+	}
+	tests := []struct {
+		name  string
+		attrs []*commonpb.KeyValue
+	}{
+		{"current", []*commonpb.KeyValue{str("http.request.method", "GET"), str("url.path", "/load"), code("http.response.status_code", 200)}},
+		{"legacy", []*commonpb.KeyValue{str("http.method", "GET"), str("http.target", "/load?n=1"), code("http.status_code", 200)}},
+	}
+	for _, tt := range tests {
+		s := newReceivedSpan(&tracepb.Span{Name: "GET /load", Attributes: tt.attrs})
+		if s.Method != "GET" || s.Path != "/load" || s.Status != 200 {
+			t.Errorf("%s: got %+v", tt.name, s)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// profileKinds are the profiles fetched from apps with Profiling. The CPU
+// profile covers the load, the others are taken at its end.
+var profileKinds = []string{"cpu", "heap", "goroutine", "mutex", "block"}
+
+// fetchProfiles waits for delay, e.g. the warm-up, then fetches a CPU profile
+// of the app on port for duration followed by the other profileKinds, and
+// writes them to dir as <kind>.pprof. A profile that fails to be fetched is
+// recorded with its error rather than failing the others.
+func fetchProfiles(ctx context.Context, baseURL, dir string, delay, duration time.Duration) []*ProfilePayload {
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+	profiles := make([]*ProfilePayload, 0, len(profileKinds))
+	for _, kind := range profileKinds {
+		p := &ProfilePayload{Kind: kind}
+		path := filepath.Join(dir, kind+".pprof")
+		url := fmt.Sprintf("%s/debug/pprof/%s", baseURL, kind)
+		if kind == "cpu" {
+			url = fmt.Sprintf("%s/debug/pprof/profile?seconds=%d", baseURL, max(1, int(duration.Round(time.Second).Seconds())))
+		}
+		n, err := fetchProfile(ctx, url, path)
+		if err != nil {
+			p.Error = err.Error()
+		} else {
+			p.Files = []string{path}
+			p.Bytes = n
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// fetchProfile writes the profile served at url to path and returns its size.
+func fetchProfile(ctx context.Context, url, path string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
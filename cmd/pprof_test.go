@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetchProfiles(t *testing.T) {
+	var cpuSeconds string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/debug/pprof/block" {
+			http.Error(w, "no block profile", http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/debug/pprof/profile" {
+			cpuSeconds = r.URL.Query().Get("seconds")
+		}
+		_, _ = w.Write([]byte("profile"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	profiles := fetchProfiles(context.Background(), srv.URL, dir, 0, 1500*time.Millisecond)
+	if len(profiles) != len(profileKinds) {
+		t.Fatalf("got %d profiles, want %d", len(profiles), len(profileKinds))
+	}
+	if cpuSeconds != "2" {
+		t.Errorf("cpu profile seconds = %q, want 2", cpuSeconds)
+	}
+	for _, p := range profiles {
+		if p.Kind == "block" {
+			if p.Error == "" || len(p.Files) != 0 {
+				t.Errorf("block = %+v, want an error", p)
+			}
+			continue
+		}
+		if p.Error != "" || p.Bytes != int64(len("profile")) {
+			t.Errorf("%s = %+v", p.Kind, p)
+			continue
+		}
+		if p.Files[0] != filepath.Join(dir, p.Kind+".pprof") {
+			t.Errorf("%s file = %s", p.Kind, p.Files[0])
+		}
+		if _, err := os.Stat(p.Files[0]); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Stage is one phase of a load profile, during which the request rate moves
+// from StartRPS to EndRPS.
+type Stage struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+	StartRPS float64 `json:"start_rps"`
+	EndRPS   float64 `json:"end_rps"`
+	// Shape is how the rate moves between StartRPS and EndRPS: "linear" (the
+	// default) or "sine", which eases in and out like half a sine wave.
+	Shape string `json:"shape,omitempty"`
+}
+
+// StageResult holds the latency of the requests scheduled during a stage.
+type StageResult struct {
+	Stage
+	Start time.Time `json:"start"`
+	Latency
+}
+
+// count returns the number of requests the stage sends in its first t
+// seconds, i.e. the integral of its rate.
+func (s *Stage) count(t float64) float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	x := min(max(t/s.Duration, 0), 1)
+	delta := s.EndRPS - s.StartRPS
+	switch s.Shape {
+	case "sine":
+		return s.Duration * (s.StartRPS*x + delta/2*(x-math.Sin(math.Pi*x)/math.Pi))
+	default:
+		return s.Duration * (s.StartRPS*x + delta*x*x/2)
+	}
+}
+
+// offset returns the time into the stage at which its n-th request is due,
+// given that count(offset) = n. count is monotonic, so bisection is enough.
+func (s *Stage) offset(n float64) float64 {
+	low, high := 0.0, s.Duration
+	for range 64 {
+		mid := (low + high) / 2
+		if s.count(mid) < n {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return high
+}
+
+// profileDuration returns the total duration of the stages in seconds.
+func profileDuration(stages []Stage) float64 {
+	var total float64
+	for _, s := range stages {
+		total += s.Duration
+	}
+	return total
+}
+
+// stageIndex returns the index of the stage that is active at the given
+// offset from the start of the load.
+func stageIndex(stages []Stage, offset time.Duration) int {
+	var end float64
+	for i, s := range stages {
+		end += s.Duration
+		if offset.Seconds() <= end {
+			return i
+		}
+	}
+	return len(stages) - 1
+}
+
+// OpenLoopProfile is like OpenLoop, but follows the rate given by the stages
+// instead of a constant one. Requests are scheduled relative to start.
+func OpenLoopProfile(ctx context.Context, start time.Time, stages []Stage, fn func(context.Context, int, time.Time) error) error {
+	var eg errgroup.Group
+	var ctxErr error
+	i := 0
+	var stageStart float64
+loop:
+	for _, stage := range stages {
+		total := stage.count(stage.Duration)
+		for n := 1.0; n <= total; n++ {
+			scheduled := start.Add(time.Duration((stageStart + stage.offset(n)) * float64(time.Second)))
+			select {
+			case <-ctx.Done():
+				ctxErr = ctx.Err()
+				break loop
+			case <-time.After(time.Until(scheduled)):
+			}
+			index := i
+			eg.Go(func() error {
+				return fn(ctx, index, scheduled)
+			})
+			i++
+		}
+		stageStart += stage.Duration
+	}
+	return cmp.Or(eg.Wait(), ctxErr)
+}
+
+// ParseProfile parses a load profile spec into stages spanning roughly the
+// given duration (in seconds). The supported specs are:
+//
+//	constant:R         R requests per second throughout
+//	ramp:A-B           rate grows linearly from A to B
+//	step:A,B,C         equally long stages at A, B and C requests per second
+//	sine:A-B/P         rate oscillates between A and B with period P (e.g. 20s)
+//	burst:A-B/E/L      rate is A, with a burst at B lasting L every E
+//
+// Sine and burst profiles are rounded to a whole number of half periods and
+// bursts respectively.
+func ParseProfile(spec string, duration float64) ([]Stage, error) {
+	kind, args, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid load profile %q: expected kind:args", spec)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid load profile %q: duration must be positive", spec)
+	}
+	parts := strings.Split(args, "/")
+	switch kind {
+	case "constant":
+		rps, err := parseRate(args)
+		if err != nil {
+			return nil, err
+		}
+		return []Stage{{Name: "constant", Duration: duration, StartRPS: rps, EndRPS: rps}}, nil
+	case "ramp":
+		from, to, err := parseRange(args)
+		if err != nil {
+			return nil, err
+		}
+		return []Stage{{Name: "ramp", Duration: duration, StartRPS: from, EndRPS: to}}, nil
+	case "step":
+		rates := strings.Split(args, ",")
+		stages := make([]Stage, 0, len(rates))
+		for i, r := range rates {
+			rps, err := parseRate(r)
+			if err != nil {
+				return nil, err
+			}
+			stages = append(stages, Stage{
+				Name:     fmt.Sprintf("step-%d", i+1),
+				Duration: duration / float64(len(rates)),
+				StartRPS: rps,
+				EndRPS:   rps,
+			})
+		}
+		return stages, nil
+	case "sine":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid sine profile %q: expected sine:A-B/P", spec)
+		}
+		low, high, err := parseRange(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		period, err := parseSeconds(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		half := period / 2
+		stages := []Stage{}
+		for i := range max(1, int(math.Round(duration/half))) {
+			s := Stage{Duration: half, Shape: "sine", StartRPS: low, EndRPS: high, Name: fmt.Sprintf("rise-%d", i/2+1)}
+			if i%2 == 1 {
+				s.StartRPS, s.EndRPS, s.Name = high, low, fmt.Sprintf("fall-%d", i/2+1)
+			}
+			stages = append(stages, s)
+		}
+		return stages, nil
+	case "burst":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid burst profile %q: expected burst:A-B/E/L", spec)
+		}
+		base, peak, err := parseRange(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		every, err := parseSeconds(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		length, err := parseSeconds(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		if length >= every {
+			return nil, fmt.Errorf("invalid burst profile %q: burst must be shorter than its interval", spec)
+		}
+		stages := []Stage{}
+		for i := range max(1, int(math.Round(duration/every))) {
+			stages = append(stages,
+				Stage{Name: fmt.Sprintf("base-%d", i+1), Duration: every - length, StartRPS: base, EndRPS: base},
+				Stage{Name: fmt.Sprintf("burst-%d", i+1), Duration: length, StartRPS: peak, EndRPS: peak},
+			)
+		}
+		return stages, nil
+	default:
+		return nil, fmt.Errorf("unknown load profile kind %q", kind)
+	}
+}
+
+func parseRate(s string) (float64, error) {
+	rps, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || rps < 0 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return rps, nil
+}
+
+func parseRange(s string) (float64, float64, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate range %q: expected A-B", s)
+	}
+	a, err := parseRate(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	b, err := parseRate(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	return a, b, nil
+}
+
+func parseSeconds(s string) (float64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d.Seconds(), nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		spec     string
+		stages   int
+		duration float64
+		wantErr  bool
+	}{
+		{spec: "constant:100", stages: 1, duration: 60},
+		{spec: "ramp:0-1000", stages: 1, duration: 60},
+		{spec: "step:100,500,1000", stages: 3, duration: 60},
+		{spec: "sine:100-1000/20s", stages: 6, duration: 60},
+		{spec: "burst:100-1000/10s/2s", stages: 12, duration: 60},
+		{spec: "ramp:1000", wantErr: true},
+		{spec: "burst:100-1000/2s/2s", wantErr: true},
+		{spec: "zigzag:1-2", wantErr: true},
+		{spec: "100", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			stages, err := ParseProfile(tt.spec, 60)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", stages)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stages) != tt.stages {
+				t.Errorf("got %d stages, want %d", len(stages), tt.stages)
+			}
+			if d := profileDuration(stages); math.Abs(d-tt.duration) > 1e-9 {
+				t.Errorf("got duration %v, want %v", d, tt.duration)
+			}
+		})
+	}
+}
+
+func TestStageCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage Stage
+		want  float64
+	}{
+		{"constant", Stage{Duration: 10, StartRPS: 100, EndRPS: 100}, 1000},
+		{"ramp", Stage{Duration: 10, StartRPS: 0, EndRPS: 100}, 500},
+		{"sine", Stage{Duration: 10, StartRPS: 0, EndRPS: 100, Shape: "sine"}, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stage.count(tt.stage.Duration); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("count = %v, want %v", got, tt.want)
+			}
+			// offset must invert count.
+			if got := tt.stage.count(tt.stage.offset(tt.want / 4)); math.Abs(got-tt.want/4) > 1e-6 {
+				t.Errorf("count(offset(%v)) = %v", tt.want/4, got)
+			}
+		})
+	}
+}
+
+func TestOpenLoopProfile(t *testing.T) {
+	stages := []Stage{
+		{Name: "slow", Duration: 0.1, StartRPS: 100, EndRPS: 100},
+		{Name: "fast", Duration: 0.1, StartRPS: 300, EndRPS: 300},
+	}
+	start := time.Now()
+	var mu sync.Mutex
+	perStage := map[int]int{}
+	indexes := map[int]bool{}
+	err := OpenLoopProfile(context.Background(), start, stages, func(_ context.Context, i int, scheduled time.Time) error {
+		mu.Lock()
+		perStage[stageIndex(stages, scheduled.Sub(start))]++
+		indexes[i] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perStage[0] != 10 || perStage[1] != 30 {
+		t.Errorf("requests per stage = %v, want 10 and 30", perStage)
+	}
+	// The index picks the endpoint of the mix, so every request needs its own.
+	for i := range 40 {
+		if !indexes[i] {
+			t.Errorf("no request with index %d", i)
+		}
+	}
+}
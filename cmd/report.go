@@ -0,0 +1,620 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/urfave/cli/v3"
+)
+
+// CmdReport is the CLI command for summarizing experiment results.
+var CmdReport = &cli.Command{
+	Name:      "report",
+	Usage:     "summarizes the results of one or more experiments",
+	ArgsUsage: "[results.json...]",
+	Description: `
+	Summarize the JSON output of "run" per scenario: latency percentiles (service time
+	and response time measured from the intended send time), throughput,
+	error rate, CPU usage and RSS of the app container, and the overhead relative
+	to the "default" scenario.
+
+	With --compare, every pair of scenarios is also compared on the per-run p50, p99
+	and CPU usage, using a bootstrap confidence interval of the difference of means and
+	a Mann-Whitney U test. Pairs whose difference is not significant are flagged, which
+	requires several runs per scenario (--num 5 or more).
+
+	Runs with a load profile (--load-profile) additionally get a per-stage breakdown,
+	runs with a request mix (--mix) a per-endpoint breakdown, and runs with --find-max
+	a table of the maximum sustainable throughput, which is also compared with --compare.
+	Runs that measured the scenario's sidecars and the OTel collector get a table of
+	the total CPU and memory of all of them, the full cost of the instrumentation.
+	Runs of instrumented scenarios get a table of how accurately their spans describe
+	the requests sent, see Fidelity.
+
+	Results of "matrix" are summarized per cell instead, labeled like
+	manual/go1.25.5/w4/throughput, which is also what --baseline then refers to.
+
+	Reads from stdin if no files are given.
+	`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format (markdown, csv)",
+			Value: "markdown",
+		},
+		&cli.StringFlag{
+			Name:  "baseline",
+			Usage: "The scenario (or matrix cell) overhead is computed against",
+			Value: "default",
+		},
+		&cli.BoolFlag{
+			Name:  "compare",
+			Usage: "Test whether the differences between scenarios are significant across runs",
+		},
+		&cli.Float64Flag{
+			Name:  "alpha",
+			Usage: "Significance level for --compare",
+			Value: 0.05,
+		},
+		&cli.IntFlag{
+			Name:  "resamples",
+			Usage: "Number of bootstrap resamples for --compare",
+			Value: 10000,
+		},
+		&cli.Uint64Flag{
+			Name:  "seed",
+			Usage: "Random seed for the bootstrap in --compare",
+			Value: 1,
+		},
+	},
+	Action: func(_ context.Context, c *cli.Command) error {
+		results, err := readResults(c.Args().Slice(), c.Reader)
+		if err != nil {
+			return err
+		}
+		write := writeMarkdown
+		switch c.String("format") {
+		case "markdown":
+		case "csv":
+			write = writeCSV
+		default:
+			return fmt.Errorf("unknown format %q", c.String("format"))
+		}
+
+		summaries := Summarize(results)
+		if err := write(c.Writer, summaryTable(summaries, c.String("baseline"))); err != nil {
+			return err
+		}
+		if stages := stageTable(results); len(stages) > 1 {
+			_, _ = fmt.Fprintln(c.Writer)
+			if err := write(c.Writer, stages); err != nil {
+				return err
+			}
+		}
+		if maxRPS := maxRPSTable(summaries, c.String("baseline")); len(maxRPS) > 1 {
+			_, _ = fmt.Fprintln(c.Writer)
+			if err := write(c.Writer, maxRPS); err != nil {
+				return err
+			}
+		}
+		if cost := costTable(summaries, c.String("baseline")); len(cost) > 1 {
+			_, _ = fmt.Fprintln(c.Writer)
+			if err := write(c.Writer, cost); err != nil {
+				return err
+			}
+		}
+		if fidelity := fidelityTable(results); len(fidelity) > 1 {
+			_, _ = fmt.Fprintln(c.Writer)
+			if err := write(c.Writer, fidelity); err != nil {
+				return err
+			}
+		}
+		if endpoints := endpointTable(results); len(endpoints) > 1 {
+			_, _ = fmt.Fprintln(c.Writer)
+			if err := write(c.Writer, endpoints); err != nil {
+				return err
+			}
+		}
+		if !c.Bool("compare") {
+			return nil
+		}
+		comparisons := Compare(results, &CompareOpts{
+			Alpha:     c.Float64("alpha"),
+			Resamples: c.Int("resamples"),
+			Seed:      c.Uint64("seed"),
+		})
+		_, _ = fmt.Fprintln(c.Writer)
+		return write(c.Writer, comparisonTable(comparisons))
+	},
+}
+
+// Summary holds the aggregated results of all runs of a scenario.
+type Summary struct {
+	Scenario string
+	Runs     int
+	Requests int
+	Errors   int
+	// Throughput is the number of successful requests per second of load.
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	P999       time.Duration
+	// ResponseP50 and ResponseP99 are percentiles of the response time, which
+	// unlike the service time includes queueing delay.
+	ResponseP50 time.Duration
+	ResponseP99 time.Duration
+	// CPU is the average CPU usage of the app container during load, in
+	// percent of a single core.
+	CPU float64
+	// RSS is the peak resident memory of the app container during load, in
+	// bytes.
+	RSS uint64
+	// MaxRPS is the average maximum sustainable throughput of the runs with
+	// --find-max, and MaxRPSRuns their number.
+	MaxRPS     float64
+	MaxRPSRuns int
+	// SidecarCPU and CollectorCPU are the average CPU usage during load of
+	// the scenario's sidecars, summed, and of the collector, in percent of a
+	// single core. TotalCPU adds that of the app, which makes it the cost of
+	// running the app with its instrumentation. All three are averaged over
+	// the CostRuns runs that measured every container.
+	SidecarCPU   float64
+	CollectorCPU float64
+	TotalCPU     float64
+	CostRuns     int
+	// SidecarRSS and TotalRSS are the peak resident memory during load of
+	// the sidecars and of all containers, summed per run, in bytes.
+	SidecarRSS uint64
+	TotalRSS   uint64
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (s *Summary) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// Summarize aggregates results per scenario, or per cell for results of a
+// matrix, in the order in which they first appear.
+func Summarize(results []*TestResult) []*Summary {
+	byScenario := map[string][]*TestResult{}
+	order := []string{}
+	for _, r := range results {
+		scenario := resultLabel(r)
+		if _, ok := byScenario[scenario]; !ok {
+			order = append(order, scenario)
+		}
+		byScenario[scenario] = append(byScenario[scenario], r)
+	}
+
+	summaries := make([]*Summary, 0, len(order))
+	for _, scenario := range order {
+		summaries = append(summaries, summarize(scenario, byScenario[scenario]))
+	}
+	return summaries
+}
+
+func summarize(scenario string, results []*TestResult) *Summary {
+	s := &Summary{Scenario: scenario, Runs: len(results)}
+	service, response := NewHistogram(), NewHistogram()
+	var loadTime time.Duration
+	var cpu float64
+	var cpuRuns int
+	for _, r := range results {
+		latency := resultLatency(r)
+		service.Merge(latency.Service)
+		response.Merge(latency.Response)
+		s.Errors += int(latency.Errors)
+		loadTime += r.LoadEnd.Sub(r.LoadStart)
+		if usage := r.LoadUsage; usage != nil && usage.Snapshots >= 2 {
+			cpu += usage.CPUAvg * 100
+			cpuRuns++
+		}
+		if r.LoadUsage != nil {
+			s.RSS = max(s.RSS, r.LoadUsage.RSSPeak)
+		}
+		if usage := r.LoadUsage; usage != nil && usage.Snapshots >= 2 && r.ContainerUsage != nil {
+			var sidecarCPU, collectorCPU float64
+			var sidecarRSS, collectorRSS uint64
+			for name, u := range r.ContainerUsage {
+				if name == collectorContainer {
+					collectorCPU, collectorRSS = u.CPUAvg*100, u.RSSPeak
+					continue
+				}
+				sidecarCPU += u.CPUAvg * 100
+				sidecarRSS += u.RSSPeak
+			}
+			s.SidecarCPU += sidecarCPU
+			s.CollectorCPU += collectorCPU
+			s.TotalCPU += usage.CPUAvg*100 + sidecarCPU + collectorCPU
+			s.CostRuns++
+			s.SidecarRSS = max(s.SidecarRSS, sidecarRSS)
+			s.TotalRSS = max(s.TotalRSS, usage.RSSPeak+sidecarRSS+collectorRSS)
+		}
+		if r.Inputs != nil && r.Inputs.FindMax != nil {
+			s.MaxRPS += float64(r.MaxRPS)
+			s.MaxRPSRuns++
+		}
+	}
+	if s.MaxRPSRuns > 0 {
+		s.MaxRPS /= float64(s.MaxRPSRuns)
+	}
+	s.Requests = int(service.Count())
+	if loadTime > 0 {
+		s.Throughput = float64(s.Requests-s.Errors) / loadTime.Seconds()
+	}
+	if cpuRuns > 0 {
+		s.CPU = cpu / float64(cpuRuns)
+	}
+	if s.CostRuns > 0 {
+		s.SidecarCPU /= float64(s.CostRuns)
+		s.CollectorCPU /= float64(s.CostRuns)
+		s.TotalCPU /= float64(s.CostRuns)
+	}
+	s.P50 = service.Quantile(0.5)
+	s.P90 = service.Quantile(0.9)
+	s.P99 = service.Quantile(0.99)
+	s.P999 = service.Quantile(0.999)
+	s.ResponseP50 = response.Quantile(0.5)
+	s.ResponseP99 = response.Quantile(0.99)
+	return s
+}
+
+// resultLatency returns the latency histograms of a result, building them
+// from its requests for results that predate histograms.
+func resultLatency(r *TestResult) *LatencyRecord {
+	if r.Latency != nil {
+		return r.Latency
+	}
+	latency := NewLatencyRecord()
+	for i := range r.Requests {
+		latency.Record(r.LoadStart, &r.Requests[i])
+	}
+	return latency
+}
+
+// summaryTable renders the summaries as rows of cells, with a header row
+// first. Overhead columns are relative to the baseline scenario and left
+// empty if it is missing.
+func summaryTable(summaries []*Summary, baseline string) [][]string {
+	var base *Summary
+	for _, s := range summaries {
+		if s.Scenario == baseline {
+			base = s
+		}
+	}
+	rows := [][]string{{
+		"scenario", "runs", "requests", "errors", "error rate", "throughput (req/s)",
+		"p50", "p90", "p99", "p99.9", "p50 (response)", "p99 (response)", "cpu (%)", "rss (MiB)",
+		"p50 overhead", "p99 overhead", "cpu overhead",
+	}}
+	for _, s := range summaries {
+		row := []string{
+			s.Scenario,
+			fmt.Sprint(s.Runs),
+			fmt.Sprint(s.Requests),
+			fmt.Sprint(s.Errors),
+			fmt.Sprintf("%.2f%%", s.ErrorRate()*100),
+			fmt.Sprintf("%.1f", s.Throughput),
+			formatDuration(s.P50),
+			formatDuration(s.P90),
+			formatDuration(s.P99),
+			formatDuration(s.P999),
+			formatDuration(s.ResponseP50),
+			formatDuration(s.ResponseP99),
+			fmt.Sprintf("%.1f", s.CPU),
+			fmt.Sprintf("%.1f", float64(s.RSS)/(1<<20)),
+		}
+		if base != nil && s != base {
+			row = append(row,
+				formatOverhead(float64(s.P50), float64(base.P50)),
+				formatOverhead(float64(s.P99), float64(base.P99)),
+				formatOverhead(s.CPU, base.CPU),
+			)
+		} else {
+			row = append(row, "", "", "")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// stageTable renders the per-stage latency of runs with a load profile,
+// merged across runs of the same scenario, with a header row first.
+func stageTable(results []*TestResult) [][]string {
+	type key struct{ scenario, stage string }
+	merged := map[key]*StageResult{}
+	runs := map[key]int{}
+	order := []key{}
+	for _, r := range results {
+		for _, stage := range r.Stages {
+			k := key{resultLabel(r), stage.Name}
+			m, ok := merged[k]
+			if !ok {
+				m = &StageResult{Stage: stage.Stage, Latency: NewLatency()}
+				merged[k] = m
+				order = append(order, k)
+			}
+			m.Merge(&stage.Latency)
+			runs[k]++
+		}
+	}
+
+	rows := [][]string{{
+		"scenario", "stage", "target (req/s)", "achieved (req/s)", "requests", "errors",
+		"p50", "p99", "p99 (response)",
+	}}
+	for _, k := range order {
+		m := merged[k]
+		target := fmt.Sprintf("%.0f", m.StartRPS)
+		if m.EndRPS != m.StartRPS {
+			target = fmt.Sprintf("%.0f-%.0f", m.StartRPS, m.EndRPS)
+		}
+		var achieved float64
+		if m.Duration > 0 {
+			achieved = float64(m.Service.Count()-m.Errors) / (m.Duration * float64(runs[k]))
+		}
+		rows = append(rows, []string{
+			k.scenario,
+			k.stage,
+			target,
+			fmt.Sprintf("%.1f", achieved),
+			fmt.Sprint(m.Service.Count()),
+			fmt.Sprint(m.Errors),
+			formatDuration(m.Service.Quantile(0.5)),
+			formatDuration(m.Service.Quantile(0.99)),
+			formatDuration(m.Response.Quantile(0.99)),
+		})
+	}
+	return rows
+}
+
+// maxRPSTable renders the maximum sustainable throughput of the scenarios
+// run with --find-max, with a header row first. The overhead column is
+// relative to the baseline scenario.
+func maxRPSTable(summaries []*Summary, baseline string) [][]string {
+	var base *Summary
+	for _, s := range summaries {
+		if s.Scenario == baseline && s.MaxRPSRuns > 0 {
+			base = s
+		}
+	}
+	rows := [][]string{{"scenario", "runs", "max (req/s)", "max overhead"}}
+	for _, s := range summaries {
+		if s.MaxRPSRuns == 0 {
+			continue
+		}
+		overhead := ""
+		if base != nil && s != base {
+			overhead = formatOverhead(s.MaxRPS, base.MaxRPS)
+		}
+		rows = append(rows, []string{
+			s.Scenario,
+			fmt.Sprint(s.MaxRPSRuns),
+			fmt.Sprintf("%.0f", s.MaxRPS),
+			overhead,
+		})
+	}
+	return rows
+}
+
+// costTable renders the resource usage of every container of the scenarios
+// whose runs measured it, with a header row first. The cost column is the
+// total CPU usage relative to that of the baseline scenario, which is the
+// instrumentation cost once sidecars and the collector are accounted for.
+func costTable(summaries []*Summary, baseline string) [][]string {
+	var base *Summary
+	for _, s := range summaries {
+		if s.Scenario == baseline && s.CostRuns > 0 {
+			base = s
+		}
+	}
+	rows := [][]string{{
+		"scenario", "runs", "app cpu (%)", "sidecar cpu (%)", "collector cpu (%)", "total cpu (%)",
+		"sidecar rss (MiB)", "total rss (MiB)", "total cpu overhead",
+	}}
+	for _, s := range summaries {
+		if s.CostRuns == 0 {
+			continue
+		}
+		overhead := ""
+		if base != nil && s != base {
+			overhead = formatOverhead(s.TotalCPU, base.TotalCPU)
+		}
+		rows = append(rows, []string{
+			s.Scenario,
+			fmt.Sprint(s.CostRuns),
+			fmt.Sprintf("%.1f", s.TotalCPU-s.SidecarCPU-s.CollectorCPU),
+			fmt.Sprintf("%.1f", s.SidecarCPU),
+			fmt.Sprintf("%.1f", s.CollectorCPU),
+			fmt.Sprintf("%.1f", s.TotalCPU),
+			fmt.Sprintf("%.1f", float64(s.SidecarRSS)/(1<<20)),
+			fmt.Sprintf("%.1f", float64(s.TotalRSS)/(1<<20)),
+			overhead,
+		})
+	}
+	return rows
+}
+
+// fidelityTable renders the fidelity of the spans of the scenarios, summed
+// across runs, with a header row first. The field columns are in percent of
+// the matched spans and the score is the average over runs.
+func fidelityTable(results []*TestResult) [][]string {
+	merged := map[string]*Fidelity{}
+	runs := map[string]int{}
+	order := []string{}
+	for _, r := range results {
+		if r.Fidelity == nil {
+			continue
+		}
+		label := resultLabel(r)
+		m, ok := merged[label]
+		if !ok {
+			m = &Fidelity{}
+			merged[label] = m
+			order = append(order, label)
+		}
+		m.Requests += r.Fidelity.Requests
+		m.Spans += r.Fidelity.Spans
+		m.Matched += r.Fidelity.Matched
+		m.Name += r.Fidelity.Name
+		m.Method += r.Fidelity.Method
+		m.Path += r.Fidelity.Path
+		m.Status += r.Fidelity.Status
+		m.Duration += r.Fidelity.Duration
+		m.Score += r.Fidelity.Score
+		runs[label]++
+	}
+
+	rows := [][]string{{
+		"scenario", "runs", "requests", "spans", "matched",
+		"name (%)", "method (%)", "path (%)", "status (%)", "duration (%)", "score",
+	}}
+	for _, label := range order {
+		m := merged[label]
+		percent := func(n int) string {
+			if m.Matched == 0 {
+				return ""
+			}
+			return fmt.Sprintf("%.1f", float64(n)/float64(m.Matched)*100)
+		}
+		rows = append(rows, []string{
+			label,
+			fmt.Sprint(runs[label]),
+			fmt.Sprint(m.Requests),
+			fmt.Sprint(m.Spans),
+			fmt.Sprint(m.Matched),
+			percent(m.Name),
+			percent(m.Method),
+			percent(m.Path),
+			percent(m.Status),
+			percent(m.Duration),
+			fmt.Sprintf("%.3f", m.Score/float64(runs[label])),
+		})
+	}
+	return rows
+}
+
+// endpointTable renders the per-endpoint latency of runs with a request mix
+// of more than one endpoint, merged across runs of the same scenario, with a
+// header row first.
+func endpointTable(results []*TestResult) [][]string {
+	type key struct{ scenario, endpoint string }
+	merged := map[key]*EndpointResult{}
+	order := []key{}
+	for _, r := range results {
+		if len(r.Endpoints) < 2 {
+			continue
+		}
+		for _, e := range r.Endpoints {
+			k := key{resultLabel(r), e.Name}
+			m, ok := merged[k]
+			if !ok {
+				m = &EndpointResult{Endpoint: e.Endpoint, Latency: NewLatency()}
+				merged[k] = m
+				order = append(order, k)
+			}
+			m.Merge(&e.Latency)
+		}
+	}
+
+	rows := [][]string{{
+		"scenario", "endpoint", "weight", "requests", "errors", "error rate",
+		"p50", "p99", "p99 (response)",
+	}}
+	for _, k := range order {
+		m := merged[k]
+		var errorRate float64
+		if n := m.Service.Count(); n > 0 {
+			errorRate = float64(m.Errors) / float64(n)
+		}
+		rows = append(rows, []string{
+			k.scenario,
+			k.endpoint,
+			fmt.Sprint(m.weight()),
+			fmt.Sprint(m.Service.Count()),
+			fmt.Sprint(m.Errors),
+			fmt.Sprintf("%.2f%%", errorRate*100),
+			formatDuration(m.Service.Quantile(0.5)),
+			formatDuration(m.Service.Quantile(0.99)),
+			formatDuration(m.Response.Quantile(0.99)),
+		})
+	}
+	return rows
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
+
+func formatOverhead(value, base float64) string {
+	if base == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f%%", (value-base)/base*100)
+}
+
+func writeMarkdown(w io.Writer, rows [][]string) error {
+	for i, row := range rows {
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+		if i == 0 {
+			sep := make([]string, len(row))
+			for j := range sep {
+				sep[j] = "---"
+			}
+			if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(sep, " | ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// readResults decodes the JSON output of "run" from the given files, or from
+// stdin if there are none.
+func readResults(paths []string, stdin io.Reader) ([]*TestResult, error) {
+	if len(paths) == 0 {
+		return decodeResults(stdin)
+	}
+	results := []*TestResult{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		r, err := decodeResults(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		results = append(results, r...)
+	}
+	return results, nil
+}
+
+func decodeResults(r io.Reader) ([]*TestResult, error) {
+	var results []*TestResult
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
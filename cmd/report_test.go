@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"fosdem2026/cmd/stats"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestSummarize(t *testing.T) {
+	start := time.Unix(0, 0)
+	snapshot := func(at time.Duration, cpu, mem uint64) *container.StatsResponse {
+		s := &container.StatsResponse{}
+		s.Read = start.Add(at)
+		s.CPUStats.CPUUsage.TotalUsage = cpu
+		s.MemoryStats.Usage = mem
+		s.MemoryStats.Stats = map[string]uint64{"inactive_file": 1 << 20}
+		return s
+	}
+	results := []*TestResult{
+		{
+			Scenario:  "default",
+			LoadStart: start,
+			LoadEnd:   start.Add(2 * time.Second),
+			Requests: []Request{
+				{Duration: time.Millisecond},
+				{Duration: 2 * time.Millisecond},
+			},
+			LoadUsage: stats.Analyze([]*container.StatsResponse{
+				snapshot(0, 0, 10<<20),
+				snapshot(time.Second, uint64(500*time.Millisecond), 12<<20),
+			}),
+		},
+		{
+			Scenario:  "manual",
+			LoadStart: start,
+			LoadEnd:   start.Add(2 * time.Second),
+			Requests: []Request{
+				{Duration: 2 * time.Millisecond},
+				{Duration: 4 * time.Millisecond, Error: "boom"},
+			},
+		},
+	}
+
+	summaries := Summarize(results)
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summaries, got %d", len(summaries))
+	}
+	def, manual := summaries[0], summaries[1]
+	if def.Scenario != "default" || manual.Scenario != "manual" {
+		t.Fatalf("unexpected scenario order: %s, %s", def.Scenario, manual.Scenario)
+	}
+	if def.Throughput != 1 {
+		t.Errorf("default throughput = %v, want 1", def.Throughput)
+	}
+	if def.CPU != 50 {
+		t.Errorf("default cpu = %v, want 50", def.CPU)
+	}
+	if def.RSS != 11<<20 {
+		t.Errorf("default rss = %v, want %v", def.RSS, 11<<20)
+	}
+	if manual.ErrorRate() != 0.5 {
+		t.Errorf("manual error rate = %v, want 0.5", manual.ErrorRate())
+	}
+
+	table := summaryTable(summaries, "default")
+	if got := table[2][len(table[2])-3]; got != "+100.0%" {
+		t.Errorf("manual p50 overhead = %q, want +100.0%%", got)
+	}
+}
+
+func TestCostTable(t *testing.T) {
+	usage := func(cpu float64, rss uint64) *stats.Stats {
+		return &stats.Stats{Summary: stats.Summary{Snapshots: 2, CPUAvg: cpu, RSSPeak: rss}}
+	}
+	results := []*TestResult{
+		{
+			Scenario:       "default",
+			LoadUsage:      usage(0.5, 10<<20),
+			ContainerUsage: map[string]*stats.Stats{collectorContainer: usage(0, 20<<20)},
+		},
+		{
+			Scenario:  "ebpf",
+			LoadUsage: usage(0.5, 10<<20),
+			ContainerUsage: map[string]*stats.Stats{
+				"go-auto":          usage(0.2, 30<<20),
+				collectorContainer: usage(0.05, 20<<20),
+			},
+		},
+		{Scenario: "manual", LoadUsage: usage(0.6, 10<<20)},
+	}
+
+	summaries := Summarize(results)
+	ebpf := summaries[1]
+	if ebpf.SidecarCPU != 20 || ebpf.CollectorCPU != 5 || ebpf.TotalCPU != 75 {
+		t.Errorf("ebpf cpu = %v/%v/%v, want 20/5/75", ebpf.SidecarCPU, ebpf.CollectorCPU, ebpf.TotalCPU)
+	}
+	if ebpf.TotalRSS != 60<<20 {
+		t.Errorf("ebpf total rss = %v, want %v", ebpf.TotalRSS, 60<<20)
+	}
+
+	table := costTable(summaries, "default")
+	if len(table) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %d rows", len(table))
+	}
+	if got := table[2][len(table[2])-1]; got != "+50.0%" {
+		t.Errorf("ebpf total cpu overhead = %q, want +50.0%%", got)
+	}
+}
@@ -2,6 +2,13 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -22,69 +29,262 @@ var CmdRun = &cli.Command{
 
 	Use scenario "all" to run all scenarios.
 
+	Available archetypes: idle (1 RPS, no work), throughput (closed loop, CPU and allocations),
+	latency (steady rate, mostly off-CPU), enterprise (mixed CPU, allocations and off-CPU across several workers)
+
 	Run with "stop" to clean up the environment.
 	`,
-	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:    "force",
-			Aliases: []string{"f"},
-			Usage:   "Do not use cached outputs, rerun everything.",
-		},
-		&cli.IntFlag{
-			Name:    "num",
-			Aliases: []string{"n"},
-			Usage:   "The number of times to repeat each experiment.",
-			Value:   1,
-		},
+	Flags: slices.Concat([]cli.Flag{
 		&cli.StringFlag{
 			Name:  "scenario",
 			Usage: "The scenario to run",
 			Value: "default",
 		},
-		&cli.DurationFlag{
-			Name:    "timeout",
-			Aliases: []string{"t"},
-			Usage:   "Timeout for each test run (e.g., 5m, 10m, 1h)",
-			Value:   5 * time.Minute,
+		&cli.StringFlag{
+			Name:    "archetype",
+			Aliases: []string{"a"},
+			Usage:   "The workload archetype to run (" + strings.Join(Archetypes(), ", ") + ")",
+			Value:   "idle",
 		},
-	},
+	}, runFlags, workloadFlags),
 	Action: func(ctx context.Context, c *cli.Command) error {
 		log, cancel := NewLogger(ctx)
 		defer cancel(nil)
-		opts := RunManyOpts{
-			Logger:   log,
-			Scenario: []string{c.String("scenario")},
-			Num:      c.Int("num"),
-			Force:    c.Bool("force"),
-			Timeout:  c.Duration("timeout"),
-			Inputs: &Input{
-				Port:           8080,
-				RuntimeVersion: "1.25.5",
-				Flush:          true,
-				RPS:            1,
-				Duration:       30,
-				Timeout:        5,
-			},
+		inputs, err := NewInput(c.String("archetype"))
+		if err != nil {
+			return err
 		}
-
-		results, err := Many(ctx, &opts)
+		if err := applyWorkloadFlags(c, inputs); err != nil {
+			return err
+		}
+		if err := inputs.Validate(); err != nil {
+			return fmt.Errorf("invalid inputs: %w", err)
+		}
+		if err := LoadScenarios(c.String("scenarios")); err != nil {
+			return err
+		}
+		opts, err := newRunManyOpts(c, log, OrderSequential)
 		if err != nil {
 			return err
 		}
+		opts.Scenario = []string{c.String("scenario")}
+		opts.Inputs = inputs
 
-		_, _ = c.Writer.Write([]byte("[\n"))
-		for i, result := range results {
-			if i > 0 {
-				_, _ = c.Writer.Write([]byte(",\n"))
-			}
-			resultJSON, err := json.Marshal(result)
-			if err != nil {
-				return err
-			}
-			_, _ = c.Writer.Write([]byte("  "))
-			_, _ = c.Writer.Write(resultJSON)
+		results, err := Many(ctx, opts)
+		if err != nil {
+			return err
 		}
-		_, _ = c.Writer.Write([]byte("\n]\n"))
-		return nil
+		return outputResults(c, results)
+	},
+}
+
+// runFlags are the flags the run and matrix commands share to control how
+// experiments are run.
+var runFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "order",
+		Usage: "The order of the runs of several scenarios: sequential (each scenario's runs back-to-back), interleaved (round-robin) or random (default: sequential for run, random for matrix)",
+	},
+	&cli.Uint64Flag{
+		Name:  "seed",
+		Usage: "Seed for --order random, random if not set",
+	},
+	&cli.BoolFlag{
+		Name:    "force",
+		Aliases: []string{"f"},
+		Usage:   "Do not use cached outputs, rerun everything.",
+	},
+	&cli.IntFlag{
+		Name:    "num",
+		Aliases: []string{"n"},
+		Usage:   "The number of times to repeat each experiment.",
+		Value:   1,
+	},
+	&cli.DurationFlag{
+		Name:    "timeout",
+		Aliases: []string{"t"},
+		Usage:   "Timeout for each test run (e.g., 5m, 10m, 1h)",
+		Value:   5 * time.Minute,
+	},
+	&cli.StringFlag{
+		Name:  "scenarios",
+		Usage: "Directory with additional scenarios defined in YAML",
+		Value: "scenarios",
+	},
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "Write the results JSON to this file instead of stdout",
+	},
+	&cli.StringFlag{
+		Name:  "results",
+		Usage: "Directory in which results are cached",
+		Value: "results",
+	},
+	&cli.BoolFlag{
+		Name:  "histograms-only",
+		Usage: "Only keep latency histograms instead of every request, for long high-rate runs",
+	},
+}
+
+// workloadFlags are the flags the run and matrix commands share to adjust
+// the workload of the archetypes, see applyWorkloadFlags.
+var workloadFlags = []cli.Flag{
+	&cli.DurationFlag{
+		Name:  "warm-up",
+		Usage: "Send load for this long before the measured load starts, recording its requests and stats separately",
+	},
+	&cli.StringFlag{
+		Name:  "load-profile",
+		Usage: "Vary the request rate over time instead of using the archetype's RPS, e.g. ramp:0-1000, step:100,500,1000, sine:100-1000/20s or burst:100-1000/10s/2s",
+	},
+	&cli.BoolFlag{
+		Name:  "exceptions",
+		Usage: "Make the request handler fail with a 500",
 	},
+	&cli.BoolFlag{
+		Name:  "panic",
+		Usage: "Fail by panicking and recovering instead of returning an error (implies --exceptions)",
+	},
+	&cli.IntFlag{
+		Name:  "stack-depth",
+		Usage: "Number of additional frames the request handler does its work below",
+	},
+	&cli.BoolFlag{
+		Name:  "recursive",
+		Usage: "Build the --stack-depth frames from a single recursive function instead of distinct functions",
+	},
+	&cli.BoolFlag{
+		Name:  "profiling",
+		Usage: "Fetch CPU, heap, goroutine, mutex and block profiles of the app during load into the --results directory",
+	},
+	&cli.StringFlag{
+		Name:  "mix",
+		Usage: "JSON file with the request mix to send instead of only hitting /load (see Endpoint)",
+	},
+	&cli.BoolFlag{
+		Name:  "find-max",
+		Usage: "Search for the highest RPS that meets --slo-p99 and --max-error-rate instead of running a fixed load (raise --timeout accordingly)",
+	},
+	&cli.DurationFlag{
+		Name:  "slo-p99",
+		Usage: "Highest acceptable p99 response time for --find-max",
+		Value: 100 * time.Millisecond,
+	},
+	&cli.Float64Flag{
+		Name:  "max-error-rate",
+		Usage: "Highest acceptable fraction of failed requests for --find-max",
+		Value: 0.01,
+	},
+	&cli.IntFlag{
+		Name:  "max-rps",
+		Usage: "Upper bound of the --find-max search",
+		Value: 100000,
+	},
+	&cli.DurationFlag{
+		Name:  "probe-duration",
+		Usage: "How long each --find-max probe runs",
+		Value: 10 * time.Second,
+	},
+}
+
+// applyWorkloadFlags applies the workloadFlags to inputs expanded from an
+// archetype.
+func applyWorkloadFlags(c *cli.Command, inputs *Input) error {
+	inputs.WarmUp = c.Duration("warm-up").Seconds()
+	if profile := c.String("load-profile"); profile != "" {
+		if _, err := ParseProfile(profile, inputs.Duration); err != nil {
+			return err
+		}
+		inputs.Profile = profile
+		inputs.RPS = 0
+		inputs.Clients = 0
+	}
+	inputs.Exceptions = c.Bool("exceptions") || c.Bool("panic")
+	inputs.Panic = c.Bool("panic")
+	inputs.StackDepth = c.Int("stack-depth")
+	inputs.Recursive = c.Bool("recursive")
+	inputs.Profiling = c.Bool("profiling")
+	if c.Bool("find-max") {
+		if inputs.Profile != "" {
+			return fmt.Errorf("--find-max and --load-profile cannot be combined")
+		}
+		inputs.FindMax = &FindMaxOpts{
+			SLOP99:        c.Duration("slo-p99").Seconds(),
+			MaxErrorRate:  c.Float64("max-error-rate"),
+			MaxRPS:        c.Int("max-rps"),
+			ProbeDuration: c.Duration("probe-duration").Seconds(),
+		}
+		inputs.Clients = 0
+	}
+	if path := c.String("mix"); path != "" {
+		mix, err := LoadMix(path)
+		if err != nil {
+			return err
+		}
+		inputs.Mix = mix
+	}
+	return nil
+}
+
+// newRunManyOpts returns the options set by the runFlags. The runs are
+// executed in defaultOrder unless --order is given.
+func newRunManyOpts(c *cli.Command, log *slog.Logger, defaultOrder Order) (*RunManyOpts, error) {
+	order := defaultOrder
+	if c.IsSet("order") {
+		var err error
+		order, err = ParseOrder(c.String("order"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	seed := c.Uint64("seed")
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &RunManyOpts{
+		Logger:         log,
+		Num:            c.Int("num"),
+		Force:          c.Bool("force"),
+		Timeout:        c.Duration("timeout"),
+		ResultsDir:     c.String("results"),
+		HistogramsOnly: c.Bool("histograms-only"),
+		Order:          order,
+		Seed:           seed,
+	}, nil
+}
+
+// outputResults writes results to the --output file, or to stdout.
+func outputResults(c *cli.Command, results []*TestResult) error {
+	output := c.String("output")
+	if output == "" {
+		return writeResults(c.Writer, results)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = writeResults(f, results)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeResults writes results as a JSON array with one result per line.
+func writeResults(w io.Writer, results []*TestResult) error {
+	_, _ = w.Write([]byte("[\n"))
+	for i, result := range results {
+		if i > 0 {
+			_, _ = w.Write([]byte(",\n"))
+		}
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write([]byte("  "))
+		_, _ = w.Write(resultJSON)
+	}
+	_, err := w.Write([]byte("\n]\n"))
+	return err
 }
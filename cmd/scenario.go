@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+const (
+	// collectorEndpoint is where instrumented apps and sidecars send
+	// telemetry.
+	collectorEndpoint = "otel-collector:4318"
+	// collectorContainer is the name of the collector's container, see
+	// infrastructure/docker-compose.yaml.
+	collectorContainer = "otel-collector"
+)
+
+// Scenario is one way of instrumenting the demo app. Each scenario lives in
+// its own scenario_*.go file and registers itself with registerScenario.
+type Scenario interface {
+	// Name identifies the scenario on the command line. It is also the name
+	// of the app image and container.
+	Name() string
+	// Build returns the options for building the app image. buildImage adds
+	// the build args from .env and the runtime version.
+	Build() *BuildOpts
+	// OwnGoVersion reports whether the app Dockerfile pins its own Go
+	// toolchain, in which case the runtime version is not passed to it.
+	OwnGoVersion() bool
+	// Configure adjusts the app container before it is created, e.g. to
+	// grant it privileges or point it to the collector.
+	Configure(inputs *Input, hostCfg *container.HostConfig)
+	// PrepareSidecars pulls or builds the images of the sidecars and returns
+	// their names.
+	PrepareSidecars(ctx context.Context, opts *RunManyOpts) ([]string, error)
+	// Setup starts the app container, which has been created but not yet
+	// started, along with any sidecars in the order they need. It returns
+	// the functions stopping the sidecars.
+	Setup(ctx context.Context, opts *RunManyOpts) ([]func(container.StopOptions) error, error)
+	// Containers lists the containers the scenario runs besides the app.
+	Containers() []string
+}
+
+var scenarios = map[string]Scenario{}
+
+// registerScenario makes a scenario available to the run command. It panics
+// if a scenario with the same name is already registered.
+func registerScenario(s Scenario) {
+	if _, ok := scenarios[s.Name()]; ok {
+		panic(fmt.Sprintf("scenario %q registered twice", s.Name()))
+	}
+	scenarios[s.Name()] = s
+}
+
+// lookupScenario returns the registered scenario with the given name.
+func lookupScenario(name string) (Scenario, error) {
+	s, ok := scenarios[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q, expected one of %s", name, strings.Join(scenarioNames(), ", "))
+	}
+	return s, nil
+}
+
+// scenarioNames returns the names of all registered scenarios in the order
+// "all" runs them: the uninstrumented baseline first, the others sorted.
+func scenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if i := slices.Index(names, "default"); i > 0 {
+		names = slices.Insert(slices.Delete(names, i, i+1), 0, "default")
+	}
+	return names
+}
+
+// containerNames returns the names of all containers the registered
+// scenarios run besides their app.
+func containerNames() []string {
+	names := []string{}
+	for _, name := range scenarioNames() {
+		names = append(names, scenarios[name].Containers()...)
+	}
+	return names
+}
+
+// StartOrder is the order in which a scenario starts its app and sidecars.
+type StartOrder int
+
+const (
+	// AppFirst starts the app, waits for it to be healthy and then starts
+	// the sidecars, for sidecars that attach to the running process.
+	AppFirst StartOrder = iota
+	// SidecarsFirst starts the sidecars before the app, for sidecars that
+	// have to be ready by the time the app produces its first output.
+	SidecarsFirst
+)
+
+// ScenarioSpec describes a scenario made of the app image and a number of
+// sidecar containers. Paths are relative to the repository root. Specs are
+// either registered from Go or loaded from YAML, see LoadScenarios.
+type ScenarioSpec struct {
+	Name string `yaml:"name"`
+	// Dir holds the Dockerfile of the app, app/<Name> if empty.
+	Dir       string            `yaml:"dir"`
+	BuildArgs map[string]string `yaml:"build_args"`
+	// OwnGoVersion is set if the Dockerfile pins its own Go toolchain.
+	OwnGoVersion bool `yaml:"own_go_version"`
+	// Instrumented scenarios get the collector as their OTel endpoint.
+	Instrumented bool `yaml:"instrumented"`
+	// SecurityOpt and Binds are applied to the app container.
+	SecurityOpt []string   `yaml:"security_opt"`
+	Binds       []string   `yaml:"binds"`
+	Order       StartOrder `yaml:"order"`
+	// Health is the app endpoint polled before starting sidecars with
+	// AppFirst, "health" if empty.
+	Health   string        `yaml:"health"`
+	Sidecars []SidecarSpec `yaml:"sidecars"`
+}
+
+// SidecarSpec describes a container running next to the app. Env and Binds
+// may refer to $APP_CONTAINER, $APP_PORT and $ROOT (the repository root).
+type SidecarSpec struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`
+	// Pull the image before creating the container.
+	Pull bool `yaml:"pull"`
+	// BuildDir holds the Dockerfile to build Image from instead, using
+	// BuildContext (the repository root if empty) as the build context.
+	BuildDir     string            `yaml:"build_dir"`
+	BuildContext string            `yaml:"build_context"`
+	BuildArgs    map[string]string `yaml:"build_args"`
+	Env          []string          `yaml:"env"`
+	// SharePID joins the PID namespace of the app container.
+	SharePID   bool     `yaml:"share_pid"`
+	Privileged bool     `yaml:"privileged"`
+	CapAdd     []string `yaml:"cap_add"`
+	Binds      []string `yaml:"binds"`
+	// HealthCheck is a Docker health check command, e.g. ["CMD", "wget",
+	// "-q", "--spider", "localhost:13133"]. If set, the sidecar has to
+	// become healthy before the next container starts.
+	HealthCheck []string `yaml:"health_check"`
+	// Settle is how long to give the sidecar after starting it, e.g. to
+	// attach to the app.
+	Settle time.Duration `yaml:"settle"`
+}
+
+// specScenario implements Scenario for a ScenarioSpec.
+type specScenario struct {
+	*ScenarioSpec
+}
+
+func (s specScenario) Name() string {
+	return s.ScenarioSpec.Name
+}
+
+func (s specScenario) Build() *BuildOpts {
+	build := &BuildOpts{
+		Dir:     filepath.Join(getRoot(), cmp.Or(s.Dir, filepath.Join("app", s.ScenarioSpec.Name))),
+		Args:    map[string]string{},
+		Secrets: map[string]string{},
+	}
+	maps.Copy(build.Args, s.BuildArgs)
+	return build
+}
+
+func (s specScenario) OwnGoVersion() bool {
+	return s.ScenarioSpec.OwnGoVersion
+}
+
+func (s specScenario) Configure(inputs *Input, hostCfg *container.HostConfig) {
+	hostCfg.SecurityOpt = s.SecurityOpt
+	hostCfg.Binds = s.Binds
+	if s.Instrumented {
+		inputs.OTelEndpoint = collectorEndpoint
+	}
+}
+
+func (s specScenario) Containers() []string {
+	names := make([]string, 0, len(s.Sidecars))
+	for _, sidecar := range s.Sidecars {
+		names = append(names, sidecar.Name)
+	}
+	return names
+}
+
+func (s specScenario) PrepareSidecars(ctx context.Context, opts *RunManyOpts) ([]string, error) {
+	images := make([]string, 0, len(s.Sidecars))
+	for i := range s.Sidecars {
+		if err := prepareSidecarImage(ctx, opts, &s.Sidecars[i]); err != nil {
+			return nil, err
+		}
+		images = append(images, s.Sidecars[i].Image)
+	}
+	return images, nil
+}
+
+func (s specScenario) Setup(ctx context.Context, opts *RunManyOpts) ([]func(container.StopOptions) error, error) {
+	log := opts.Logger
+	app := s.Name()
+
+	startApp := func() error {
+		if err := dockerClient.ContainerStart(ctx, app, container.StartOptions{}); err != nil {
+			log.Debug("❌ Failed to start app container", "scenario", app, "error", err)
+			return err
+		}
+		return nil
+	}
+
+	stops := []func(container.StopOptions) error{}
+	startSidecars := func() error {
+		for i := range s.Sidecars {
+			stop, err := startSidecar(ctx, opts, app, &s.Sidecars[i])
+			if err != nil {
+				return err
+			}
+			stops = append(stops, stop)
+		}
+		return nil
+	}
+
+	switch s.Order {
+	case SidecarsFirst:
+		if err := startSidecars(); err != nil {
+			return stops, err
+		}
+		if err := startApp(); err != nil {
+			return stops, err
+		}
+	default:
+		if err := startApp(); err != nil {
+			return nil, err
+		}
+		if len(s.Sidecars) > 0 {
+			if err := waitForAppHealth(ctx, opts.Inputs.Port, cmp.Or(s.Health, "health")); err != nil {
+				log.Warn("⚠️ health check failed before starting sidecars", "scenario", app, "error", err)
+			}
+		}
+		if err := startSidecars(); err != nil {
+			return stops, err
+		}
+	}
+	if len(s.Sidecars) > 0 {
+		log.Info("✅ sidecars started", "scenario", app, "sidecars", s.Containers())
+	}
+	return stops, nil
+}
+
+// prepareSidecarImage pulls or builds the image of a sidecar.
+func prepareSidecarImage(ctx context.Context, opts *RunManyOpts, spec *SidecarSpec) error {
+	log := opts.Logger
+	switch {
+	case spec.BuildDir != "":
+		log.Info("⌛ Building sidecar image...", "image", spec.Image)
+		build := &BuildOpts{
+			Dir:     filepath.Join(getRoot(), spec.BuildDir),
+			Args:    map[string]string{},
+			Secrets: map[string]string{},
+		}
+		maps.Copy(build.Args, spec.BuildArgs)
+		if spec.BuildContext != "" {
+			build.ContextDir = filepath.Join(getRoot(), spec.BuildContext)
+		}
+		buildCmd := dockerClient.BuildCommand(ctx, build, spec.Image)
+		buildCmd.Stdout = os.Stdout
+		buildCmd.Stderr = os.Stderr
+		buildCmd.Env = os.Environ()
+		if err := buildCmd.Run(); err != nil {
+			log.Error("❌ Failed to build sidecar image", "image", spec.Image, "error", err)
+			return err
+		}
+		log.Info("✅ Sidecar image built", "image", spec.Image)
+	case spec.Pull:
+		log.Info("⌛ Pulling sidecar image...", "image", spec.Image)
+		if err := run("docker", "pull", spec.Image); err != nil {
+			log.Error("❌ Failed to pull sidecar image", "image", spec.Image, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// startSidecar creates, connects and starts a sidecar of the app container
+// and returns the function stopping it.
+func startSidecar(ctx context.Context, opts *RunManyOpts, app string, spec *SidecarSpec) (func(container.StopOptions) error, error) {
+	log := opts.Logger
+	expand := sidecarExpander(app, opts.Inputs.Port)
+
+	// Remove existing container if it exists (from previous runs)
+	_ = dockerClient.ContainerRemove(ctx, spec.Name, container.RemoveOptions{Force: true})
+
+	hostCfg := &container.HostConfig{
+		Privileged: spec.Privileged,
+		CapAdd:     spec.CapAdd,
+		Binds:      expandAll(spec.Binds, expand),
+	}
+	if spec.SharePID {
+		hostCfg.PidMode = container.PidMode("container:" + app)
+	}
+	cfg := &container.Config{
+		Image: spec.Image,
+		Env:   expandAll(spec.Env, expand),
+	}
+	if len(spec.HealthCheck) > 0 {
+		cfg.Healthcheck = &container.HealthConfig{Test: spec.HealthCheck, Interval: time.Second}
+	}
+	_, err := dockerClient.ContainerCreate(ctx, cfg, hostCfg, nil, nil, spec.Name)
+	if err != nil {
+		log.Error("❌ Failed to create sidecar container", "sidecar", spec.Name, "error", err)
+		return nil, err
+	}
+
+	if err := dockerClient.NetworkConnect(ctx, networkName, spec.Name, nil); err != nil {
+		// Ignore "already exists" errors - container may already be connected
+		if !strings.Contains(err.Error(), "already exists") {
+			log.Error("❌ Failed to connect sidecar to network", "sidecar", spec.Name, "error", err)
+			return nil, err
+		}
+	}
+
+	if err := dockerClient.ContainerStart(ctx, spec.Name, container.StartOptions{}); err != nil {
+		log.Error("❌ Failed to start sidecar", "sidecar", spec.Name, "error", err)
+		return nil, err
+	}
+	if len(spec.HealthCheck) > 0 {
+		if err := waitForContainerHealth(ctx, spec.Name); err != nil {
+			log.Error("❌ Sidecar did not become healthy", "sidecar", spec.Name, "error", err)
+			return nil, err
+		}
+	}
+	time.Sleep(spec.Settle)
+
+	return func(opts container.StopOptions) error {
+		return dockerClient.ContainerStop(ctx, spec.Name, opts)
+	}, nil
+}
+
+// waitForContainerHealth waits for the Docker health check of a container to
+// pass.
+func waitForContainerHealth(ctx context.Context, name string) error {
+	healthCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for {
+		info, err := dockerClient.ContainerInspect(healthCtx, name)
+		if err != nil {
+			return err
+		}
+		if info.State != nil && info.State.Health != nil {
+			switch info.State.Health.Status {
+			case container.Healthy:
+				return nil
+			case container.Unhealthy:
+				return fmt.Errorf("container %s is unhealthy", name)
+			}
+		}
+		select {
+		case <-healthCtx.Done():
+			return context.Cause(healthCtx)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// sidecarExpander returns the mapping for the variables sidecar env and
+// binds may refer to.
+func sidecarExpander(app string, port int) func(string) string {
+	return func(name string) string {
+		switch name {
+		case "APP_CONTAINER":
+			return app
+		case "APP_PORT":
+			return strconv.Itoa(port)
+		case "ROOT":
+			return getRoot()
+		}
+		return ""
+	}
+}
+
+func expandAll(values []string, mapping func(string) string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = os.Expand(v, mapping)
+	}
+	return out
+}
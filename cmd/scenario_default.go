@@ -0,0 +1,10 @@
+package cmd
+
+// The default scenario runs the app without any instrumentation. It is the
+// baseline the other scenarios are compared against.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name: "default",
+		Dir:  "app/baseline",
+	}})
+}
@@ -0,0 +1,25 @@
+package cmd
+
+// The ebpf scenario attaches OpenTelemetry Go auto-instrumentation to the
+// running app with eBPF.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "ebpf",
+		Instrumented: true,
+		Sidecars: []SidecarSpec{{
+			Name:  "go-auto",
+			Image: "otel/autoinstrumentation-go",
+			Pull:  true,
+			Env: []string{
+				"OTEL_GO_AUTO_TARGET_EXE=/app/main",
+				"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
+				"OTEL_EXPORTER_OTLP_PROTOCOL=http/protobuf",
+				"OTEL_SERVICE_NAME=fosdem-ebpf",
+				"OTEL_PROPAGATORS=tracecontext,baggage",
+			},
+			SharePID:   true,
+			Privileged: true,
+			Binds:      []string{"/proc:/host/proc"},
+		}},
+	}})
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import "time"
+
+// The flightrecorder scenario has the app write execution traces to a shared
+// volume, from which the exporter sidecar converts them into spans. The
+// exporter starts first so that it is watching by the time traces appear.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "flightrecorder",
+		Instrumented: true,
+		Binds:        []string{"flightrecorder_traces:/tmp/traces"},
+		Order:        SidecarsFirst,
+		Sidecars: []SidecarSpec{{
+			Name:     "flightrecorder-exporter",
+			Image:    "flightrecorder-exporter",
+			BuildDir: "app/flightrecorder/exporter",
+			// Use the exporter dir as context to avoid picking up other files.
+			BuildContext: "app/flightrecorder/exporter",
+			Env: []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
+				"TRACE_OUTPUT_DIR=/tmp/traces",
+			},
+			Binds:  []string{"flightrecorder_traces:/tmp/traces"},
+			Settle: 2 * time.Second,
+		}},
+	}})
+}
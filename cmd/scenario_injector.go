@@ -0,0 +1,25 @@
+package cmd
+
+import "time"
+
+// The injector scenario hooks the handlers of the running app with Frida.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "injector",
+		Instrumented: true,
+		Sidecars: []SidecarSpec{{
+			Name:     "go-injector",
+			Image:    "injector-sidecar",
+			BuildDir: "app/injector/sidecar",
+			Env: []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
+				"TARGET_PID=1",
+			},
+			SharePID:   true,
+			Privileged: true,
+			CapAdd:     []string{"SYS_PTRACE"},
+			Binds:      []string{"/proc:/host/proc"},
+			Settle:     3 * time.Second,
+		}},
+	}})
+}
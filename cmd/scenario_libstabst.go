@@ -0,0 +1,34 @@
+package cmd
+
+import "time"
+
+// The libstabst scenario fires USDT probes registered at runtime with
+// libstapsdt, which bpftrace picks up in the exporter sidecar.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "libstabst",
+		Instrumented: true,
+		// Requires Go <= 1.23.x for salp library compatibility.
+		OwnGoVersion: true,
+		// libstapsdt needs these to work.
+		// NOTE: This doesn't fully fix the issue on Docker Desktop (macOS) - see README.md.
+		// libstapsdt uses memfd_create + dlopen("/proc/<pid>/fd/<fd>") which fails
+		// due to /proc access restrictions in Docker Desktop's Linux VM.
+		SecurityOpt: []string{"seccomp=unconfined", "apparmor=unconfined"},
+		Sidecars: []SidecarSpec{{
+			Name:     "go-usdt",
+			Image:    "bpftrace-exporter",
+			BuildDir: "app/exporter",
+			Env: []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
+				"TARGET_PID=1",
+				"BPFTRACE_SCRIPT=/app/libstabst.bt",
+				"EXPORTER_MODE=libstabst",
+			},
+			SharePID:   true,
+			Privileged: true,
+			Binds:      []string{"/proc:/host/proc", "/sys:/sys:ro"},
+			Settle:     3 * time.Second,
+		}},
+	}})
+}
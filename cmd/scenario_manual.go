@@ -0,0 +1,9 @@
+package cmd
+
+// The manual scenario instruments the app by hand with the OTel SDK.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "manual",
+		Instrumented: true,
+	}})
+}
@@ -0,0 +1,28 @@
+package cmd
+
+// The obi scenario instruments the running app with OpenTelemetry eBPF
+// Instrumentation, configured by infrastructure/obi-config.yaml.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "obi",
+		Instrumented: true,
+		Sidecars: []SidecarSpec{{
+			Name:  "go-obi",
+			Image: "otel/ebpf-instrument:main",
+			Pull:  true,
+			Env: []string{
+				"OBI_CONFIG_PATH=/etc/obi/config.yaml",
+				"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
+				"OTEL_SERVICE_NAME=fosdem-obi",
+				"OTEL_EBPF_OPEN_PORT=$APP_PORT",
+				"OTEL_EBPF_PROMETHEUS_PORT=9090",
+			},
+			SharePID:   true,
+			Privileged: true,
+			Binds: []string{
+				"/proc:/host/proc",
+				"$ROOT/infrastructure/obi-config.yaml:/etc/obi/config.yaml:ro",
+			},
+		}},
+	}})
+}
@@ -0,0 +1,10 @@
+package cmd
+
+// The orchestrion scenario instruments the app at compile time with
+// Orchestrion, see app/orchestrion/orchestrion.yml.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "orchestrion",
+		Instrumented: true,
+	}})
+}
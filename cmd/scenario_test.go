@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestScenarioNames(t *testing.T) {
+	names := scenarioNames()
+	if len(names) == 0 || names[0] != "default" {
+		t.Fatalf("scenarioNames() = %v, want the default scenario first", names)
+	}
+	if !slices.IsSorted(names[1:]) {
+		t.Errorf("scenarioNames() = %v, want the others sorted", names)
+	}
+	for _, name := range names {
+		s, err := lookupScenario(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Name() != name {
+			t.Errorf("lookupScenario(%q).Name() = %q", name, s.Name())
+		}
+	}
+	if _, err := lookupScenario("nope"); err == nil {
+		t.Error("lookupScenario(\"nope\") succeeded")
+	}
+}
+
+func TestContainerNames(t *testing.T) {
+	names := containerNames()
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			t.Errorf("container %q used by more than one sidecar", name)
+		}
+		if _, ok := scenarios[name]; ok {
+			t.Errorf("sidecar %q has the name of a scenario", name)
+		}
+		seen[name] = true
+	}
+	for _, name := range []string{"go-auto", "go-obi", "flightrecorder-exporter"} {
+		if !seen[name] {
+			t.Errorf("containerNames() = %v, missing %q", names, name)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	for _, tt := range []struct {
+		scenario string
+		endpoint string
+	}{
+		{"default", ""},
+		{"manual", collectorEndpoint},
+	} {
+		s, err := lookupScenario(tt.scenario)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := &Input{}
+		s.Configure(inputs, &container.HostConfig{})
+		if inputs.OTelEndpoint != tt.endpoint {
+			t.Errorf("%s: OTelEndpoint = %q, want %q", tt.scenario, inputs.OTelEndpoint, tt.endpoint)
+		}
+	}
+}
+
+func TestExpandAll(t *testing.T) {
+	got := expandAll([]string{"OPEN_PORT=$APP_PORT", "PID=container:${APP_CONTAINER}", "UNSET=$NOPE"}, sidecarExpander("obi", 8080))
+	want := []string{"OPEN_PORT=8080", "PID=container:obi", "UNSET="}
+	if !slices.Equal(got, want) {
+		t.Errorf("expandAll() = %v, want %v", got, want)
+	}
+}
+
+// TestSidecarBuildContexts checks that the files the sidecar Dockerfiles copy
+// exist in their build context.
+func TestSidecarBuildContexts(t *testing.T) {
+	for _, name := range scenarioNames() {
+		s, ok := scenarios[name].(specScenario)
+		if !ok {
+			continue
+		}
+		for _, sidecar := range s.Sidecars {
+			if sidecar.BuildDir == "" {
+				continue
+			}
+			context := filepath.Join(getRoot(), sidecar.BuildContext)
+			data, err := os.ReadFile(filepath.Join(getRoot(), sidecar.BuildDir, "Dockerfile"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 3 || fields[0] != "COPY" || strings.HasPrefix(fields[1], "--from") {
+					continue
+				}
+				for _, src := range fields[1 : len(fields)-1] {
+					if strings.HasPrefix(src, "--") {
+						continue
+					}
+					if matches, _ := filepath.Glob(filepath.Join(context, src)); len(matches) == 0 {
+						t.Errorf("%s: %s copies %s, which is not in its build context %s", name, sidecar.Name, src, context)
+					}
+				}
+			}
+		}
+	}
+}
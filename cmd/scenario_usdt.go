@@ -0,0 +1,29 @@
+package cmd
+
+import "time"
+
+// The usdt scenario uses a Go fork with native USDT probes, which bpftrace
+// picks up in the exporter sidecar.
+func init() {
+	registerScenario(specScenario{&ScenarioSpec{
+		Name:         "usdt",
+		Instrumented: true,
+		// Uses a custom Go fork with native USDT support.
+		OwnGoVersion: true,
+		Sidecars: []SidecarSpec{{
+			Name:     "go-usdt-native",
+			Image:    "bpftrace-exporter",
+			BuildDir: "app/exporter",
+			Env: []string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
+				"TARGET_PID=1",
+				"BPFTRACE_SCRIPT=/app/native-usdt.bt",
+				"EXPORTER_MODE=native-usdt",
+			},
+			SharePID:   true,
+			Privileged: true,
+			Binds:      []string{"/proc:/host/proc", "/sys:/sys:ro"},
+			Settle:     3 * time.Second,
+		}},
+	}})
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// startOrders maps the YAML names of the start orders to their values.
+var startOrders = map[string]StartOrder{
+	"app_first":      AppFirst,
+	"sidecars_first": SidecarsFirst,
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (o *StartOrder) UnmarshalYAML(value *yaml.Node) error {
+	order, ok := startOrders[value.Value]
+	if !ok {
+		return fmt.Errorf("line %d: unknown start order %q, expected app_first or sidecars_first", value.Line, value.Value)
+	}
+	*o = order
+	return nil
+}
+
+// ParseScenario parses and validates a scenario definition in YAML.
+func ParseScenario(data []byte) (*ScenarioSpec, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var spec ScenarioSpec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks that the scenario can be run.
+func (s *ScenarioSpec) Validate() error {
+	var errs []error
+	if s.Name == "" {
+		errs = append(errs, errors.New("name must be set"))
+	}
+	names := map[string]bool{}
+	for i, sidecar := range s.Sidecars {
+		switch {
+		case sidecar.Name == "":
+			errs = append(errs, fmt.Errorf("sidecar %d: name must be set", i))
+		case sidecar.Name == s.Name:
+			errs = append(errs, fmt.Errorf("sidecar %s: name is that of the app", sidecar.Name))
+		case names[sidecar.Name]:
+			errs = append(errs, fmt.Errorf("sidecar %s: duplicate name", sidecar.Name))
+		}
+		names[sidecar.Name] = true
+		if sidecar.Image == "" {
+			errs = append(errs, fmt.Errorf("sidecar %s: image must be set", sidecar.Name))
+		}
+		if sidecar.Pull && sidecar.BuildDir != "" {
+			errs = append(errs, fmt.Errorf("sidecar %s: pull and build_dir cannot be set at the same time", sidecar.Name))
+		}
+		// The app container has no PID namespace to join until it runs.
+		if sidecar.SharePID && s.Order != AppFirst {
+			errs = append(errs, fmt.Errorf("sidecar %s: share_pid requires the app_first start order", sidecar.Name))
+		}
+		if sidecar.Settle < 0 {
+			errs = append(errs, fmt.Errorf("sidecar %s: settle must not be negative", sidecar.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadScenarios registers the scenarios defined in the *.yaml files of dir,
+// next to the ones defined in Go. A missing directory is not an error.
+func LoadScenarios(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		spec, err := ParseScenario(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if _, ok := scenarios[spec.Name]; ok {
+			return fmt.Errorf("%s: scenario %q is already defined", path, spec.Name)
+		}
+		for _, sidecar := range spec.Sidecars {
+			if slices.Contains(containerNames(), sidecar.Name) {
+				return fmt.Errorf("%s: sidecar %q is already used by another scenario", path, sidecar.Name)
+			}
+		}
+		registerScenario(specScenario{spec})
+	}
+	return nil
+}
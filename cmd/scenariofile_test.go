@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScenarioYAML = `
+name: obi-debug
+dir: app/obi
+build_args:
+  log_level: debug
+instrumented: true
+order: app_first
+health: health
+sidecars:
+  - name: go-obi-debug
+    image: otel/ebpf-instrument:main
+    pull: true
+    env:
+      - OTEL_EBPF_OPEN_PORT=$APP_PORT
+    share_pid: true
+    privileged: true
+    binds:
+      - /proc:/host/proc
+    health_check: ["CMD", "true"]
+    settle: 3s
+`
+
+func TestParseScenario(t *testing.T) {
+	spec, err := ParseScenario([]byte(testScenarioYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Name != "obi-debug" || spec.Dir != "app/obi" || !spec.Instrumented || spec.Order != AppFirst {
+		t.Errorf("unexpected scenario %+v", spec)
+	}
+	if spec.BuildArgs["log_level"] != "debug" {
+		t.Errorf("BuildArgs = %v", spec.BuildArgs)
+	}
+	if len(spec.Sidecars) != 1 {
+		t.Fatalf("got %d sidecars, want 1", len(spec.Sidecars))
+	}
+	sidecar := spec.Sidecars[0]
+	if !sidecar.Pull || !sidecar.SharePID || !sidecar.Privileged || sidecar.Settle != 3*time.Second {
+		t.Errorf("unexpected sidecar %+v", sidecar)
+	}
+	if !slices.Equal(sidecar.HealthCheck, []string{"CMD", "true"}) {
+		t.Errorf("HealthCheck = %v", sidecar.HealthCheck)
+	}
+
+	build := specScenario{spec}.Build()
+	if build.Args["log_level"] != "debug" || !strings.HasSuffix(build.Dir, filepath.Join("app", "obi")) {
+		t.Errorf("Build() = %+v", build)
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown field", "name: x\nsidecar: []\n", "field sidecar not found"},
+		{"no name", "dir: app/obi\n", "name must be set"},
+		{"order", "name: x\norder: later\n", "unknown start order"},
+		{"no image", "name: x\nsidecars: [{name: y}]\n", "image must be set"},
+		{"duplicate", "name: x\nsidecars: [{name: y, image: a}, {name: y, image: b}]\n", "duplicate name"},
+		{"share pid", "name: x\norder: sidecars_first\nsidecars: [{name: y, image: a, share_pid: true}]\n", "share_pid requires"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScenario([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseScenario() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisteredScenariosValidate(t *testing.T) {
+	for name, s := range scenarios {
+		if spec, ok := s.(specScenario); ok {
+			if err := spec.Validate(); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	if err := LoadScenarios(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("LoadScenarios(missing) = %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "obi-debug.yaml"), []byte(testScenarioYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { delete(scenarios, "obi-debug") })
+	if err := LoadScenarios(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lookupScenario("obi-debug"); err != nil {
+		t.Error(err)
+	}
+	if !slices.Contains(containerNames(), "go-obi-debug") {
+		t.Errorf("containerNames() = %v, missing go-obi-debug", containerNames())
+	}
+	if err := LoadScenarios(dir); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("loading twice: error = %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package stats turns the container stats snapshots taken during a test run
+// into a compact time series and aggregates, so that results don't have to
+// carry the full Docker payload.
+package stats
+
+import (
+	"slices"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Sample is the resource usage of a container between two consecutive
+// snapshots.
+type Sample struct {
+	// Time is when the later snapshot was read.
+	Time time.Time `json:"t"`
+	// CPU is the number of cores used on average since the previous sample.
+	CPU float64 `json:"cpu"`
+	// RSS is the memory usage in bytes, excluding the page cache.
+	RSS uint64 `json:"rss"`
+	// NetRx and NetTx are the bytes received and sent since the previous
+	// sample, across all interfaces.
+	NetRx uint64 `json:"net_rx"`
+	NetTx uint64 `json:"net_tx"`
+}
+
+// Summary aggregates the resource usage of a container over a window.
+type Summary struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Snapshots is the number of snapshots the summary is based on.
+	Snapshots int `json:"snapshots"`
+	// CPUAvg is the average number of cores used over the window, CPUMax the
+	// highest of any sample.
+	CPUAvg float64 `json:"cpu_avg"`
+	CPUMax float64 `json:"cpu_max"`
+	// RSSPeak is the highest memory usage, RSSSteady the median over the
+	// second half of the window, once start-up allocations have settled.
+	RSSPeak   uint64 `json:"rss_peak"`
+	RSSSteady uint64 `json:"rss_steady"`
+	// NetRx and NetTx are the bytes received and sent over the window.
+	NetRx uint64 `json:"net_rx"`
+	NetTx uint64 `json:"net_tx"`
+	// PageFaults and MajorPageFaults count the faults over the window.
+	PageFaults      uint64 `json:"page_faults"`
+	MajorPageFaults uint64 `json:"major_page_faults"`
+	// ThrottledPeriods and ThrottledTime measure how much the CPU quota of
+	// the container held it back over the window.
+	ThrottledPeriods uint64        `json:"throttled_periods"`
+	ThrottledTime    time.Duration `json:"throttled_time"`
+}
+
+// Stats holds the resource usage of a container over a window.
+type Stats struct {
+	Summary
+	Series []Sample `json:"series"`
+}
+
+// Analyze summarizes snapshots, which are ordered by the time they were read.
+// Rates need two snapshots, so a single one only yields memory usage.
+func Analyze(snapshots []*container.StatsResponse) *Stats {
+	s := &Stats{Series: []Sample{}}
+	s.Snapshots = len(snapshots)
+	if len(snapshots) == 0 {
+		return s
+	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	s.Start, s.End = first.Read, last.Read
+
+	rss := make([]uint64, len(snapshots))
+	for i, snap := range snapshots {
+		rss[i] = RSS(snap)
+		s.RSSPeak = max(s.RSSPeak, rss[i])
+	}
+	steady := slices.Clone(rss[len(rss)/2:])
+	slices.Sort(steady)
+	s.RSSSteady = steady[len(steady)/2]
+
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		rx, tx := network(cur)
+		prevRx, prevTx := network(prev)
+		sample := Sample{
+			Time:  cur.Read,
+			CPU:   cores(prev, cur),
+			RSS:   rss[i],
+			NetRx: delta(prevRx, rx),
+			NetTx: delta(prevTx, tx),
+		}
+		s.CPUMax = max(s.CPUMax, sample.CPU)
+		s.NetRx += sample.NetRx
+		s.NetTx += sample.NetTx
+		s.Series = append(s.Series, sample)
+	}
+	s.CPUAvg = cores(first, last)
+	faults, majorFaults := pageFaults(first)
+	lastFaults, lastMajorFaults := pageFaults(last)
+	s.PageFaults = delta(faults, lastFaults)
+	s.MajorPageFaults = delta(majorFaults, lastMajorFaults)
+	throttling, lastThrottling := first.CPUStats.ThrottlingData, last.CPUStats.ThrottlingData
+	s.ThrottledPeriods = delta(throttling.ThrottledPeriods, lastThrottling.ThrottledPeriods)
+	s.ThrottledTime = time.Duration(delta(throttling.ThrottledTime, lastThrottling.ThrottledTime))
+	return s
+}
+
+// RSS returns the memory usage of a snapshot, excluding the page cache the
+// same way "docker stats" does.
+func RSS(s *container.StatsResponse) uint64 {
+	usage := s.MemoryStats.Usage
+	// cgroup v1 reports total_inactive_file, cgroup v2 inactive_file.
+	inactive, ok := s.MemoryStats.Stats["total_inactive_file"]
+	if !ok {
+		inactive = s.MemoryStats.Stats["inactive_file"]
+	}
+	if inactive < usage {
+		usage -= inactive
+	}
+	return usage
+}
+
+// cores returns the average number of cores used between two snapshots.
+func cores(prev, cur *container.StatsResponse) float64 {
+	elapsed := cur.Read.Sub(prev.Read)
+	if elapsed <= 0 {
+		return 0
+	}
+	used := delta(prev.CPUStats.CPUUsage.TotalUsage, cur.CPUStats.CPUUsage.TotalUsage)
+	return float64(used) / float64(elapsed.Nanoseconds())
+}
+
+func network(s *container.StatsResponse) (rx, tx uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	return rx, tx
+}
+
+func pageFaults(s *container.StatsResponse) (faults, majorFaults uint64) {
+	// cgroup v1 prefixes the hierarchical counters with total_.
+	if v, ok := s.MemoryStats.Stats["total_pgfault"]; ok {
+		return v, s.MemoryStats.Stats["total_pgmajfault"]
+	}
+	return s.MemoryStats.Stats["pgfault"], s.MemoryStats.Stats["pgmajfault"]
+}
+
+// delta returns the increase of a cumulative counter, or zero if it was
+// reset in between, e.g. because the container restarted.
+func delta(prev, cur uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
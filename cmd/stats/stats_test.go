@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func snapshot(at time.Duration, cpu, mem, rx, faults uint64) *container.StatsResponse {
+	s := &container.StatsResponse{}
+	s.Read = time.Unix(0, 0).Add(at)
+	s.CPUStats.CPUUsage.TotalUsage = cpu
+	s.MemoryStats.Usage = mem
+	s.MemoryStats.Stats = map[string]uint64{"inactive_file": 1 << 20, "pgfault": faults}
+	s.Networks = map[string]container.NetworkStats{
+		"eth0": {RxBytes: rx, TxBytes: rx / 2},
+		"eth1": {RxBytes: rx},
+	}
+	return s
+}
+
+func TestAnalyze(t *testing.T) {
+	s := Analyze([]*container.StatsResponse{
+		snapshot(0, 0, 10<<20, 0, 100),
+		snapshot(time.Second, uint64(500*time.Millisecond), 20<<20, 1000, 150),
+		snapshot(2*time.Second, uint64(2500*time.Millisecond), 12<<20, 3000, 175),
+		snapshot(3*time.Second, uint64(3000*time.Millisecond), 13<<20, 3000, 200),
+	})
+	if s.Snapshots != 4 || len(s.Series) != 3 {
+		t.Fatalf("got %d snapshots and %d samples, want 4 and 3", s.Snapshots, len(s.Series))
+	}
+	if s.CPUAvg != 1 {
+		t.Errorf("CPUAvg = %v, want 1", s.CPUAvg)
+	}
+	if s.CPUMax != 2 {
+		t.Errorf("CPUMax = %v, want 2", s.CPUMax)
+	}
+	if s.RSSPeak != 19<<20 {
+		t.Errorf("RSSPeak = %v, want %v", s.RSSPeak, 19<<20)
+	}
+	// The median of the second half, 11 and 12 MiB.
+	if s.RSSSteady != 12<<20 {
+		t.Errorf("RSSSteady = %v, want %v", s.RSSSteady, 12<<20)
+	}
+	if s.NetRx != 6000 || s.NetTx != 1500 {
+		t.Errorf("NetRx, NetTx = %v, %v, want 6000, 1500", s.NetRx, s.NetTx)
+	}
+	if s.PageFaults != 100 {
+		t.Errorf("PageFaults = %v, want 100", s.PageFaults)
+	}
+	if got := s.Series[1]; got.CPU != 2 || got.NetRx != 4000 || got.RSS != 11<<20 {
+		t.Errorf("second sample = %+v", got)
+	}
+}
+
+func TestAnalyzeFewSnapshots(t *testing.T) {
+	if s := Analyze(nil); s.Snapshots != 0 || len(s.Series) != 0 {
+		t.Errorf("Analyze(nil) = %+v", s)
+	}
+	s := Analyze([]*container.StatsResponse{snapshot(0, 0, 10<<20, 0, 0)})
+	if s.RSSPeak != 9<<20 || s.RSSSteady != 9<<20 || s.CPUAvg != 0 {
+		t.Errorf("Analyze(single snapshot) = %+v", s)
+	}
+}
+
+func TestAnalyzeCounterReset(t *testing.T) {
+	s := Analyze([]*container.StatsResponse{
+		snapshot(0, uint64(time.Second), 10<<20, 5000, 0),
+		snapshot(time.Second, 0, 10<<20, 0, 0),
+	})
+	if s.CPUAvg != 0 || s.NetRx != 0 {
+		t.Errorf("reset counters yield CPUAvg %v and NetRx %v, want 0", s.CPUAvg, s.NetRx)
+	}
+}
@@ -16,29 +16,28 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"fosdem2026/app/schema"
+	"fosdem2026/cmd/stats"
+
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/mount"
 	types "github.com/docker/docker/api/types/network"
 	"github.com/docker/go-connections/nat"
 	"github.com/joho/godotenv"
-	"golang.org/x/sync/errgroup"
 )
 
 var (
-	dockerCommand  = "docker-compose"
-	dockerClient   *Client
-	serverPID      *os.Process
-	allScenarios   = []string{"default", "manual", "obi", "ebpf", "orchestrion", "injector", "libstabst", "usdt", "flightrecorder"}
-	containerNames = []string{"go-auto", "go-obi", "collector", "go-usdt", "go-injector", "go-usdt-native", "flightrecorder-exporter"}
-	networkName    = "fosdem2026"
+	dockerCommand = "docker-compose"
+	dockerClient  *Client
+	serverPID     *os.Process
+	networkName   = "fosdem2026"
 )
 
-// Many runs multiple test scenarios and returns results.
+// Many runs multiple test scenarios with the same inputs and returns results.
 func Many(ctx context.Context, opts *RunManyOpts) ([]*TestResult, error) {
 	log := opts.Logger
-	results := []*TestResult{}
 
 	// Stop and clean up environment
 	if opts.Scenario[0] == "stop" {
@@ -47,34 +46,184 @@ func Many(ctx context.Context, opts *RunManyOpts) ([]*TestResult, error) {
 	}
 
 	if opts.Scenario[0] == "all" {
-		opts.Scenario = allScenarios
+		opts.Scenario = scenarioNames()
 	}
+	jobs := make([]*job, 0, len(opts.Scenario))
+	for _, name := range opts.Scenario {
+		sc, err := lookupScenario(name)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job{scenario: sc, inputs: opts.Inputs})
+	}
+	return runJobs(ctx, opts, jobs)
+}
+
+// job is a scenario to run opts.Num times with the given inputs.
+type job struct {
+	scenario Scenario
+	inputs   *Input
+	// cell locates the job in a matrix, if it is part of one.
+	cell *Cell
+	// opts is set by prepareScenario.
+	opts     *RunManyOpts
+	failures int
+}
+
+// logAttrs returns the attributes identifying the job in logs.
+func (j *job) logAttrs() []any {
+	attrs := []any{"scenario", j.scenario.Name()}
+	if j.cell != nil {
+		attrs = append(attrs, "cell", j.cell.String())
+	}
+	return attrs
+}
+
+// runJobs runs every job opts.Num times. Each result is cached in
+// opts.ResultsDir under the hash of its inputs, and runs that already have a
+// cached result are skipped unless opts.Force is set. The remaining runs
+// are executed in opts.Order.
+func runJobs(ctx context.Context, opts *RunManyOpts, jobs []*job) ([]*TestResult, error) {
+	log := opts.Logger
+	results := []*TestResult{}
 
 	err := setupEnvironment(ctx, opts)
 	if err != nil {
 		log.Debug("Failed to setup environment", "error", err)
 		return nil, err
 	}
-	for _, s := range opts.Scenario {
-		log.Info("Running scenario", "scenario", s)
-		failures := 0
-		for i := range opts.Num {
-			log.Info("Running test run", "run", i+1, "of", opts.Num)
-			r, err := runOne(ctx, opts, s)
+
+	pending := []pendingRun{}
+	// Jobs of the same scenario share its image, so keep track of which job
+	// it was last built for.
+	built := map[string]*job{}
+	for _, j := range jobs {
+		s := j.scenario.Name()
+		log.Info("Preparing scenario", j.logAttrs()...)
+		jobOpts := *opts
+		jobOpts.Inputs = j.inputs
+		j.opts, err = prepareScenario(ctx, &jobOpts, j.scenario)
+		if err != nil {
+			log.Warn("⚠️ Scenario preparation failed", "scenario", s, "error", err)
+			continue
+		}
+		built[s] = j
+		hash := j.opts.Inputs.Hash
+
+		cached := []*TestResult{}
+		if !opts.Force {
+			cached, err = loadResults(opts.ResultsDir, hash)
 			if err != nil {
-				log.Warn("⚠️ Test run failed", "error", err)
-				failures++
+				log.Warn("⚠️ Failed to load cached results, rerunning", "hash", hash, "error", err)
+				cached = nil
+			}
+		}
+		done := map[int]bool{}
+		for _, r := range cached {
+			if r.Run < opts.Num {
+				done[r.Run] = true
+				r.Cell = j.cell
+				results = append(results, r)
+			}
+		}
+		if len(done) > 0 {
+			log.Info("♻️ Using cached results", "scenario", s, "hash", hash, "cached", len(done), "of", opts.Num)
+		}
+		for i := range opts.Num {
+			if !done[i] {
+				pending = append(pending, pendingRun{j, i})
+			}
+		}
+	}
+	orderRuns(pending, opts.Order, opts.Seed)
+	if opts.Order == OrderRandom {
+		log.Info("🎲 Shuffled runs", "runs", len(pending), "seed", opts.Seed)
+	}
+
+	for i, p := range pending {
+		j := p.job
+		s := j.scenario.Name()
+		if built[s] != j {
+			if err := buildImage(ctx, j.opts, j.scenario); err != nil {
+				log.Warn("⚠️ Image build failed", "scenario", s, "error", err)
+				j.failures++
 				continue
 			}
-			results = append(results, r)
+			built[s] = j
+		}
+		log.Info("Running test run", append(j.logAttrs(), "run", p.run+1, "of", opts.Num, "progress", fmt.Sprintf("%d/%d", i+1, len(pending)))...)
+		r, err := runOne(ctx, j.opts, j.scenario, p.run)
+		if err != nil {
+			log.Warn("⚠️ Test run failed", "error", err)
+			j.failures++
+			continue
+		}
+		r.Scenario = s
+		r.Hash = j.opts.Inputs.Hash
+		r.Run = p.run
+		r.Inputs = j.opts.Inputs
+		r.Cell = j.cell
+		r.Order = opts.Order
+		if opts.Order == OrderRandom {
+			r.Seed = opts.Seed
+		}
+		r.Position = i
+		if err := saveResult(opts.ResultsDir, r); err != nil {
+			log.Warn("⚠️ Failed to cache result", "error", err)
+		}
+		results = append(results, r)
+	}
+	for _, j := range jobs {
+		if j.opts != nil {
+			log.Info("Scenario completed", append(j.logAttrs(), "failures", j.failures)...)
 		}
-		log.Info("Scenario completed", "scenario", s, "failures", failures)
 	}
 	return results, nil
 }
 
-func runOne(ctx context.Context, opts *RunManyOpts, scenario string) (*TestResult, error) {
+// prepareScenario builds the images for the scenario and its sidecars and
+// returns a copy of opts whose inputs carry the hash identifying the
+// scenario's results.
+func prepareScenario(ctx context.Context, opts *RunManyOpts, sc Scenario) (*RunManyOpts, error) {
+	if err := buildImage(ctx, opts, sc); err != nil {
+		return nil, err
+	}
+	scenario := sc.Name()
+	image, err := dockerClient.ImageInspect(ctx, scenario)
+	if err != nil {
+		return nil, err
+	}
+	sidecars, err := sc.PrepareSidecars(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	sidecarIDs := make([]string, 0, len(sidecars))
+	for _, name := range sidecars {
+		sidecar, err := dockerClient.ImageInspect(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		sidecarIDs = append(sidecarIDs, sidecar.ID)
+	}
+
+	// Each scenario gets its own copy of the inputs, so that scenario-specific
+	// settings (e.g. the OTel endpoint) don't leak into the next one. They
+	// are applied before hashing so that the hash matches the inputs the
+	// results are stored with.
+	inputs := *opts.Inputs
+	sc.Configure(&inputs, &container.HostConfig{})
+	inputs.Hash, err = hashInputs(&inputs, scenario, image.ID, sidecarIDs, opts.HistogramsOnly)
+	if err != nil {
+		return nil, err
+	}
+	scenarioOpts := *opts
+	scenarioOpts.Inputs = &inputs
+	return &scenarioOpts, nil
+}
+
+func runOne(ctx context.Context, opts *RunManyOpts, sc Scenario, run int) (*TestResult, error) {
 	log := opts.Logger
+	scenario := sc.Name()
 	log.Info("Starting test run")
 	start := time.Now()
 
@@ -96,62 +245,35 @@ func runOne(ctx context.Context, opts *RunManyOpts, scenario string) (*TestResul
 		RunnerCPU:  runtime.NumCPU(),
 	}
 	inputs := opts.Inputs
+	if err := inputs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid inputs: %w", err)
+	}
 
-	cleanupFunctions := []func(container.StopOptions) error{}
-	cleanup, err := buildGoEnvironment(ctx, opts, scenario)
+	// Count the spans the scenario exports, to tell whether it dropped any.
+	receiver, err := startTraceReceiver(receiverAddr)
+	if err != nil {
+		log.Warn("⚠️ Failed to start trace receiver, not counting spans", "error", err)
+	} else {
+		defer func() { _ = receiver.Stop(context.Background()) }()
+	}
+
+	cleanup, err := buildGoEnvironment(ctx, opts, sc)
 	if err != nil {
 		log.Debug("Failed to build Go environment", "error", err)
 		return nil, err
 	}
-	if scenario != "default" {
-		err := setupOTelEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-	}
-	if scenario == "obi" {
-		cleanupOBI, err := setupOBIEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		cleanupFunctions = append(cleanupFunctions, cleanupOBI)
-	}
-	if scenario == "ebpf" {
-		cleanupEBPF, err := setupEBPFEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		cleanupFunctions = append(cleanupFunctions, cleanupEBPF)
-	}
-	if scenario == "libstabst" {
-		cleanupLibstabst, err := setupLibstabstEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		cleanupFunctions = append(cleanupFunctions, cleanupLibstabst)
-	}
-	if scenario == "injector" {
-		cleanupInjector, err := setupInjectorEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		cleanupFunctions = append(cleanupFunctions, cleanupInjector)
-	}
-	if scenario == "usdt" {
-		cleanupUSDT, err := setupUSDTEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
-		}
-		cleanupFunctions = append(cleanupFunctions, cleanupUSDT)
-	}
-	if scenario == "flightrecorder" {
-		cleanupFR, err := setupFlightRecorderEnvironment(ctx, opts)
-		if err != nil {
-			return nil, err
+	cleanupFunctions, err := sc.Setup(ctx, opts)
+	if err != nil {
+		// Stop whatever did start, so that the next run starts from scratch.
+		for _, stop := range append(cleanupFunctions, cleanup) {
+			_ = stop(container.StopOptions{Signal: "SIGKILL"})
 		}
-		cleanupFunctions = append(cleanupFunctions, cleanupFR)
+		return nil, err
 	}
 	cleanupFunctions = append(cleanupFunctions, cleanup)
+	// Logs are followed from the start, so nothing is lost by starting the
+	// capture once the containers run.
+	logs := captureLogs(ctx, append([]string{scenario}, sc.Containers()...), runDir(opts.ResultsDir, inputs.Hash, run))
 
 	log.Info("✅ app build done")
 	appStop := make(chan struct{}, 1)
@@ -179,9 +301,55 @@ func runOne(ctx context.Context, opts *RunManyOpts, scenario string) (*TestResul
 	log.Info("✅ app server is healthy", "duration", time.Since(waitStart))
 	out.AppReady = time.Now()
 
+	// The app calibrates LoopsNum and AllocsNum from LoopsCPU and AllocsCPU on
+	// startup, record the counts it ended up with.
+	calibration, err := fetchCalibration(ctx, inputs.Port)
+	if err != nil {
+		log.Debug("Failed to fetch calibration", "error", err)
+		return nil, err
+	}
+	out.LoopsNum = calibration.LoopsNum
+	out.AllocsNum = calibration.AllocsNum
+	log.Info("✅ app calibrated", "loops_num", out.LoopsNum, "allocs_num", out.AllocsNum)
+
+	peakRPS := inputs.RPS
+	if inputs.FindMax != nil {
+		peakRPS = inputs.FindMax.MaxRPS
+	}
+	var stages []Stage
+	if inputs.Profile != "" {
+		stages, err = ParseProfile(inputs.Profile, inputs.Duration)
+		if err != nil {
+			return nil, err
+		}
+		for _, stage := range stages {
+			peakRPS = max(peakRPS, int(stage.StartRPS), int(stage.EndRPS))
+		}
+	}
+
+	mix := inputs.Mix
+	if len(mix) == 0 {
+		mix = defaultMix(inputs)
+	}
+
 	// generate load
+	// The cost of some scenarios lies in their sidecars rather than the app,
+	// and instrumented ones also keep the collector busy. Each collector
+	// blocks until its first snapshot, so start them all before the load.
+	containerStats := startAllStats(ctx, append([]string{scenario}, append(sc.Containers(), collectorContainer)...))
+	loadStats := containerStats[scenario]
+	delete(containerStats, scenario)
 	out.LoadStart = time.Now()
-	stats := startStats(ctx, scenario)
+
+	var profiles chan []*ProfilePayload
+	if inputs.Profiling && inputs.FindMax == nil {
+		profiles = make(chan []*ProfilePayload, 1)
+		go func() {
+			delay := time.Duration(inputs.WarmUp * float64(time.Second))
+			duration := time.Duration(inputs.Duration * float64(time.Second))
+			profiles <- fetchProfiles(ctx, fmt.Sprintf("http://localhost:%d", inputs.Port), runDir(opts.ResultsDir, inputs.Hash, run), delay, duration)
+		}()
+	}
 
 	// Send requests
 	client := &http.Client{
@@ -189,31 +357,88 @@ func runOne(ctx context.Context, opts *RunManyOpts, scenario string) (*TestResul
 		Transport: &http.Transport{
 			Proxy:               http.ProxyFromEnvironment,
 			TLSClientConfig:     &tls.Config{InsecureSkipVerify: false},
-			MaxIdleConnsPerHost: inputs.RPS,
+			MaxIdleConnsPerHost: max(peakRPS, inputs.Clients),
 		},
 	}
-	requests, err := Generate(ctx, &Config{
-		Client:      client,
-		Log:         log,
-		URL:         fmt.Sprintf("http://localhost:%d/load", inputs.Port),
-		RPS:         inputs.RPS,
-		Clients:     inputs.Clients,
-		Duration:    inputs.Duration,
-		ExpectError: inputs.Exceptions,
-		Endpoints:   1,
-	})
-	if err != nil {
-		log.Debug("Failed to generate requests", "error", err)
-		return nil, err
+	config := &Config{
+		Client:         client,
+		Log:            log,
+		URL:            fmt.Sprintf("http://localhost:%d", inputs.Port),
+		RPS:            inputs.RPS,
+		Clients:        inputs.Clients,
+		Duration:       inputs.Duration,
+		Endpoints:      mix,
+		HistogramsOnly: opts.HistogramsOnly,
+		Stages:         stages,
+		WarmUp:         inputs.WarmUp,
+	}
+	if inputs.FindMax != nil {
+		if inputs.WarmUp > 0 {
+			warmUp := *config
+			warmUp.RPS = max(inputs.RPS, 1)
+			out.WarmUp, err = WarmUp(ctx, &warmUp)
+			if err != nil {
+				log.Debug("Failed to warm up", "error", err)
+				return nil, err
+			}
+		}
+		out.MaxRPS, out.Probes, err = findMax(ctx, inputs.FindMax, inputs.RPS, func(ctx context.Context, rps int) (*Probe, error) {
+			p, err := runProbe(ctx, config, inputs.FindMax, rps)
+			time.Sleep(probeCooldown)
+			return p, err
+		})
+		if err != nil {
+			log.Debug("Failed to find max throughput", "error", err)
+			return nil, err
+		}
+		log.Info("✅ max sustainable throughput found", "rps", out.MaxRPS, "probes", len(out.Probes))
+	} else {
+		load, err := Generate(ctx, config)
+		if err != nil {
+			log.Debug("Failed to generate requests", "error", err)
+			return nil, err
+		}
+		out.Requests = load.Requests
+		out.Latency = load.Latency
+		out.Stages = load.Stages
+		out.Endpoints = load.Endpoints
+		out.WarmUp = load.WarmUp
 	}
-	out.Requests = requests
 
 	out.LoadEnd = time.Now()
-	out.LoadStats, err = stats()
+	if profiles != nil {
+		out.Profiles = <-profiles
+		for _, p := range out.Profiles {
+			if p.Error != "" {
+				log.Warn("⚠️ Failed to fetch profile", "kind", p.Kind, "error", p.Error)
+			}
+		}
+	}
+	snapshots, err := loadStats()
 	if err != nil {
 		log.Debug("Failed to get load stats", "error", err)
 		return nil, err
 	}
+	if out.WarmUp != nil {
+		// The measured load starts after the warm-up.
+		out.LoadStart = out.WarmUp.End
+		var warmUp []*container.StatsResponse
+		warmUp, snapshots = splitStats(snapshots, out.WarmUp.End)
+		out.WarmUp.Usage = stats.Analyze(warmUp)
+	}
+	out.LoadUsage = stats.Analyze(snapshots)
+	out.ContainerUsage = map[string]*stats.Stats{}
+	for name, stop := range containerStats {
+		snapshots, err := stop()
+		if len(snapshots) == 0 {
+			log.Warn("⚠️ No stats for container", "container", name, "error", err)
+			continue
+		}
+		if out.WarmUp != nil {
+			_, snapshots = splitStats(snapshots, out.WarmUp.End)
+		}
+		out.ContainerUsage[name] = stats.Analyze(snapshots)
+	}
 	time.Sleep(5 * time.Second) // wait for the app to finish processing
 
 	stopStats := startStats(ctx, scenario)
@@ -232,12 +457,40 @@ func runOne(ctx context.Context, opts *RunManyOpts, scenario string) (*TestResul
 		}
 	}
 	out.StopEnd = time.Now()
+	out.Logs = logs.Wait(5 * time.Second)
+	for _, l := range out.Logs {
+		if l.Error != "" || l.Errors > 0 || l.Warnings > 0 {
+			log.Warn("⚠️ Container logged problems", "container", l.Container, "errors", l.Errors, "warnings", l.Warnings, "file", l.File, "error", l.Error)
+		}
+	}
 
-	out.StopStats, err = stopStats()
+	snapshots, err = stopStats()
 	if err != nil {
 		log.Debug("Failed to get end stats", "error", err)
 		return nil, err
 	}
+	out.StopUsage = stats.Analyze(snapshots)
+
+	if receiver != nil {
+		// There is no single load to compare with when finding the maximum
+		// throughput.
+		checked := inputs.OTelEndpoint != "" && inputs.FindMax == nil
+		requests := int(resultLatency(out).Service.Count())
+		if checked {
+			receiver.Wait(ctx, requests, out.LoadStart, out.LoadEnd, traceDrainTimeout)
+		}
+		out.Traces = receiver.traces(out.LoadStart, out.LoadEnd)
+		if traces := tracesCount(out.Traces); checked && traces != requests {
+			out.TelemetryIncomplete = true
+			log.Warn("⚠️ Telemetry incomplete", "requests", requests, "traces", traces)
+		}
+		// Without the requests (--histograms-only) there is nothing to match
+		// the spans with.
+		if checked && len(out.Requests) > 0 {
+			out.Fidelity = validateFidelity(out.Requests, mix, receiver.rootSpans(out.LoadStart, out.LoadEnd))
+			log.Info("✅ telemetry validated", "score", out.Fidelity.Score, "matched", out.Fidelity.Matched, "spans", out.Fidelity.Spans)
+		}
+	}
 	return out, nil
 }
 
@@ -279,6 +532,33 @@ func httpHealthCheck(ctx context.Context, port int, endpoint string) (bool, erro
 	return resp.StatusCode == http.StatusOK, nil
 }
 
+func fetchCalibration(ctx context.Context, port int) (*schema.Calibration, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+	}
+	url := fmt.Sprintf("http://localhost:%d/calibrate", port)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calibrate: unexpected status %d", resp.StatusCode)
+	}
+	var c schema.Calibration
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("calibrate: %w", err)
+	}
+	return &c, nil
+}
+
 func setupEnvironment(ctx context.Context, opts *RunManyOpts) error {
 	log := opts.Logger
 
@@ -317,10 +597,12 @@ func setupEnvironment(ctx context.Context, opts *RunManyOpts) error {
 	return nil
 }
 
-func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string) (func(container.StopOptions) error, error) {
+func buildImage(ctx context.Context, opts *RunManyOpts, sc Scenario) error {
 	// Build the Go application
 	log := opts.Logger
-	buildArgs := map[string]string{}
+	scenario := sc.Name()
+	build := sc.Build()
+	buildArgs := build.Args
 
 	// First, load all variables from .env file
 	envFile := filepath.Join(".env")
@@ -331,14 +613,7 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 	}
 
 	// Skip runtime_version for scenarios that manage their own Go versions
-	// - libstabst: requires Go <= 1.23.x for salp library compatibility
-	// - usdt: uses custom Go fork with native USDT support
-	scenariosWithOwnGoVersion := map[string]bool{
-		"libstabst": true,
-		"usdt":      true,
-	}
-
-	if !scenariosWithOwnGoVersion[scenario] {
+	if !sc.OwnGoVersion() {
 		if opts.Inputs.RuntimeVersion != "" {
 			buildArgs["runtime_version"] = opts.Inputs.RuntimeVersion
 		} else {
@@ -347,33 +622,27 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 		}
 	}
 
-	// Build the Dockerfile for the given scenario
-	build := &BuildOpts{
-		Dir:     filepath.Join(getRoot(), "app", scenario),
-		Args:    buildArgs,
-		Secrets: map[string]string{},
-	}
-	var eg errgroup.Group
-	eg.Go(func() error {
-		log.Info("⌛ image build starting", "scenario", scenario)
-		start := time.Now()
-		cmdLog := log.With("scenario", scenario)
-		buildCmd := dockerClient.BuildCommand(ctx, build, scenario)
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		log.Info("executing", "command", buildCmd.String())
-		buildCmd.Env = os.Environ()
-		if err := buildCmd.Run(); err != nil {
-			log.Debug("Failed to build image", "error", err)
-			return err
-		}
-		cmdLog.Info("✅ image build done", "duration", time.Since(start))
-		return nil
-	})
-
-	if err := eg.Wait(); err != nil {
-		return nil, err
+	log.Info("⌛ image build starting", "scenario", scenario)
+	start := time.Now()
+	cmdLog := log.With("scenario", scenario)
+	buildCmd := dockerClient.BuildCommand(ctx, build, scenario)
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	log.Info("executing", "command", buildCmd.String())
+	buildCmd.Env = os.Environ()
+	if err := buildCmd.Run(); err != nil {
+		log.Debug("Failed to build image", "error", err)
+		return err
 	}
+	cmdLog.Info("✅ image build done", "duration", time.Since(start))
+	return nil
+}
+
+// buildGoEnvironment creates the app container from the image built by
+// buildImage. The scenario starts it in Setup.
+func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, sc Scenario) (func(container.StopOptions) error, error) {
+	log := opts.Logger
+	scenario := sc.Name()
 
 	// Create the container
 	port := opts.Inputs.Port
@@ -383,28 +652,7 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 		},
 	}
 
-	// libstabst scenario needs special security options for libstapsdt to work.
-	// NOTE: This doesn't fully fix the issue on Docker Desktop (macOS) - see README.md.
-	// libstapsdt uses memfd_create + dlopen("/proc/<pid>/fd/<fd>") which fails
-	// due to /proc access restrictions in Docker Desktop's Linux VM.
-	if scenario == "libstabst" {
-		hostCfg.SecurityOpt = []string{"seccomp=unconfined", "apparmor=unconfined"}
-	}
-
-	// flightrecorder scenario needs a shared volume for trace files
-	if scenario == "flightrecorder" {
-		hostCfg.Mounts = []mount.Mount{
-			{
-				Type:   mount.TypeVolume,
-				Source: "flightrecorder_traces",
-				Target: "/tmp/traces",
-			},
-		}
-	}
-
-	if scenario != "default" {
-		opts.Inputs.OtelEndpoint = "otel-collector:4318"
-	}
+	sc.Configure(opts.Inputs, hostCfg)
 
 	// Remove existing container if it exists (from previous runs)
 	_ = dockerClient.ContainerRemove(ctx, scenario, container.RemoveOptions{Force: true})
@@ -416,7 +664,7 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 			nat.Port(fmt.Sprintf("%d/tcp", port)): struct{}{},
 		},
 		Env: []string{
-			fmt.Sprintf("OTEL_EXPORTER_OTLP_ENDPOINT=%s", opts.Inputs.OtelEndpoint),
+			fmt.Sprintf("OTEL_EXPORTER_OTLP_ENDPOINT=%s", opts.Inputs.OTelEndpoint),
 		},
 	}, hostCfg, nil, nil, scenario)
 	if err != nil {
@@ -432,8 +680,9 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 		}
 	}
 
-	// Handle inputs.json before starting the container
-	data, err := json.MarshalIndent(opts.Inputs, "", "  ")
+	// Handle inputs.json before starting the container. The application only
+	// gets its part of the inputs, see schema.Input.
+	data, err := json.MarshalIndent(&opts.Inputs.Input, "", "  ")
 	if err != nil {
 		return nil, err
 	}
@@ -461,451 +710,6 @@ func buildGoEnvironment(ctx context.Context, opts *RunManyOpts, scenario string)
 		return dockerClient.ContainerStop(ctx, scenario, opts)
 	}
 
-	// Start the container ONLY if not ebpf/obi/libstabst/injector/usdt/flightrecorder (they start in their setup functions)
-	if scenario == "ebpf" || scenario == "obi" || scenario == "libstabst" || scenario == "injector" || scenario == "usdt" || scenario == "flightrecorder" {
-		return cleanup, nil
-	}
-
-	if err := dockerClient.ContainerStart(ctx, scenario, container.StartOptions{}); err != nil {
-		log.Debug("Failed to start container", "error", err)
-		return nil, err
-	}
-	log.Info("✅ app build done")
-
-	return cleanup, nil
-}
-
-func setupOTelEnvironment(_ context.Context, opts *RunManyOpts) error {
-	log := opts.Logger
-	// TODO: should this go here?
-	log.Info("✅ OTel environment setup complete")
-	return nil
-}
-
-func setupEBPFEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	// Setup eBPF environment
-	log := opts.Logger
-
-	log.Info("⌛ Pulling eBPF image...")
-	if err := run("docker", "pull", "otel/autoinstrumentation-go"); err != nil {
-		log.Error("❌ Failed to pull eBPF image", "error", err)
-		return nil, err
-	}
-
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "otel/autoinstrumentation-go",
-		Env: []string{
-			"OTEL_GO_AUTO_TARGET_EXE=/app/main",
-			"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
-			"OTEL_EXPORTER_OTLP_PROTOCOL=http/protobuf",
-			"OTEL_SERVICE_NAME=fosdem-ebpf",
-			"OTEL_PROPAGATORS=tracecontext,baggage",
-		},
-	}, &container.HostConfig{
-		PidMode:    container.PidMode("container:ebpf"),
-		Privileged: true,
-		Binds:      []string{"/proc:/host/proc"},
-	}, nil, nil, "go-auto")
-	if err != nil {
-		log.Error("❌ Failed to create eBPF container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.ContainerStart(ctx, "ebpf", container.StartOptions{}); err != nil {
-		log.Debug("❌ Failed to start container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.NetworkConnect(ctx, networkName, "go-auto", nil); err != nil {
-		log.Error("❌ Failed to connect go-auto to network", "error", err)
-		return nil, err
-	}
-	if err := waitForAppHealth(ctx, opts.Inputs.Port, "13133"); err != nil {
-		log.Warn("⚠️ health check failed during eBPF setup", "error", err)
-	}
-	if err := dockerClient.ContainerStart(ctx, "go-auto", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start eBPF sidecar", "error", err)
-		return nil, err
-	}
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "go-auto", opts)
-	}
-
-	log.Info("✅ eBPF environment setup complete")
-	return cleanup, nil
-}
-
-func setupOBIEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	log := opts.Logger
-
-	log.Info("⌛ Pulling OBI image...")
-	if err := run("docker", "pull", "otel/ebpf-instrument:main"); err != nil {
-		log.Error("❌ Failed to pull OBI image", "error", err)
-		return nil, err
-	}
-
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "otel/ebpf-instrument:main",
-		Env: []string{
-			"OBI_CONFIG_PATH=/etc/obi/config.yaml",
-			"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
-			"OTEL_SERVICE_NAME=fosdem-obi",
-			"OTEL_EBPF_OPEN_PORT=" + strconv.Itoa(opts.Inputs.Port),
-			"OTEL_EBPF_PROMETHEUS_PORT=9090",
-		},
-	}, &container.HostConfig{
-		PidMode:    container.PidMode("container:obi"),
-		Privileged: true,
-		Binds: []string{
-			"/proc:/host/proc",
-			filepath.Join(getRoot(), "infrastructure/obi-config.yaml") + ":/etc/obi/config.yaml:ro",
-		},
-	}, nil, nil, "go-obi")
-	if err != nil {
-		log.Error("❌ Failed to create OBI container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.ContainerStart(ctx, "obi", container.StartOptions{}); err != nil {
-		log.Debug("❌ Failed to start container", "error", err)
-		return nil, err
-	}
-	if err := dockerClient.NetworkConnect(ctx, networkName, "go-obi", nil); err != nil {
-		log.Error("❌ Failed to connect go-obi to network", "error", err)
-		return nil, err
-	}
-	if err := waitForAppHealth(ctx, opts.Inputs.Port, "13133"); err != nil {
-		log.Warn("⚠️ health check failed during OBI setup", "error", err)
-	}
-	if err := dockerClient.ContainerStart(ctx, "go-obi", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start OBI sidecar", "error", err)
-		return nil, err
-	}
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "go-obi", opts)
-	}
-
-	log.Info("✅ OBI environment setup complete")
-	return cleanup, nil
-}
-
-func setupLibstabstEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	log := opts.Logger
-
-	log.Info("⌛ Setting up libstabst/USDT tracing environment...")
-
-	// Build the unified exporter image
-	log.Info("⌛ Building unified exporter image...")
-	exporterBuild := &BuildOpts{
-		Dir:     getRoot(),
-		Args:    map[string]string{},
-		Secrets: map[string]string{},
-	}
-	buildCmd := dockerClient.BuildCommand(ctx, exporterBuild, "bpftrace-exporter")
-	buildCmd.Args = append(buildCmd.Args, "-f", filepath.Join(getRoot(), "app/exporter/Dockerfile"))
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-	buildCmd.Env = os.Environ()
-	if err := buildCmd.Run(); err != nil {
-		log.Error("❌ Failed to build exporter image", "error", err)
-		return nil, err
-	}
-	log.Info("✅ Exporter image built")
-
-	// Start the application container first
-	if err := dockerClient.ContainerStart(ctx, "libstabst", container.StartOptions{}); err != nil {
-		log.Debug("❌ Failed to start libstabst app container", "error", err)
-		return nil, err
-	}
-
-	// Wait for app to be ready
-	if err := waitForAppHealth(ctx, opts.Inputs.Port, "health"); err != nil {
-		log.Warn("⚠️ health check failed during libstabst app startup", "error", err)
-	}
-
-	// Create bpftrace exporter container with unified exporter in libstabst mode
-	log.Info("⌛ Creating libstabst exporter container...")
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "bpftrace-exporter",
-		Env: []string{
-			"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
-			"TARGET_PID=1",
-			"BPFTRACE_SCRIPT=/app/libstabst.bt",
-			"EXPORTER_MODE=libstabst",
-		},
-	}, &container.HostConfig{
-		PidMode:    container.PidMode("container:libstabst"),
-		Privileged: true,
-		Binds: []string{
-			"/proc:/host/proc",
-			"/sys:/sys:ro",
-		},
-	}, nil, nil, "go-usdt")
-	if err != nil {
-		log.Error("❌ Failed to create libstabst exporter container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.NetworkConnect(ctx, networkName, "go-usdt", nil); err != nil {
-		log.Error("❌ Failed to connect go-usdt to network", "error", err)
-		return nil, err
-	}
-
-	// Start the exporter container
-	if err := dockerClient.ContainerStart(ctx, "go-usdt", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start libstabst exporter", "error", err)
-		return nil, err
-	}
-
-	// Give exporter a moment to attach
-	time.Sleep(3 * time.Second)
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "go-usdt", opts)
-	}
-
-	log.Info("✅ libstabst environment setup complete")
-	return cleanup, nil
-}
-
-func setupInjectorEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	log := opts.Logger
-
-	log.Info("⌛ Setting up Frida injector environment...")
-
-	// Build the sidecar image first
-	log.Info("⌛ Building Frida sidecar image...")
-	sidecarBuild := &BuildOpts{
-		Dir:     filepath.Join(getRoot(), "app/injector"),
-		Args:    map[string]string{},
-		Secrets: map[string]string{},
-	}
-	buildCmd := dockerClient.BuildCommand(ctx, sidecarBuild, "injector-sidecar")
-	buildCmd.Args = append(buildCmd.Args, "-f", filepath.Join(getRoot(), "app/injector/sidecar/Dockerfile"))
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-	buildCmd.Env = os.Environ()
-	if err := buildCmd.Run(); err != nil {
-		log.Error("❌ Failed to build sidecar image", "error", err)
-		return nil, err
-	}
-	log.Info("✅ Sidecar image built")
-
-	// Start the application container first
-	if err := dockerClient.ContainerStart(ctx, "injector", container.StartOptions{}); err != nil {
-		log.Debug("❌ Failed to start injector app container", "error", err)
-		return nil, err
-	}
-
-	// Wait for app to be ready
-	if err := waitForAppHealth(ctx, opts.Inputs.Port, "health"); err != nil {
-		log.Warn("⚠️ health check failed during injector app startup", "error", err)
-	}
-
-	// Create Frida sidecar container
-	log.Info("⌛ Creating Frida sidecar container...")
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "injector-sidecar",
-		Env: []string{
-			"OTEL_EXPORTER_OTLP_ENDPOINT=http://otel-collector:4318",
-			"TARGET_PID=1",
-		},
-	}, &container.HostConfig{
-		PidMode:    container.PidMode("container:injector"),
-		Privileged: true,
-		CapAdd:     []string{"SYS_PTRACE"},
-		Binds: []string{
-			"/proc:/host/proc",
-		},
-	}, nil, nil, "go-injector")
-	if err != nil {
-		log.Error("❌ Failed to create Frida sidecar container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.NetworkConnect(ctx, networkName, "go-injector", nil); err != nil {
-		log.Error("❌ Failed to connect go-injector to network", "error", err)
-		return nil, err
-	}
-
-	// Start the sidecar container
-	if err := dockerClient.ContainerStart(ctx, "go-injector", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start Frida sidecar", "error", err)
-		return nil, err
-	}
-
-	// Give sidecar a moment to attach
-	time.Sleep(3 * time.Second)
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "go-injector", opts)
-	}
-
-	log.Info("✅ Frida injector environment setup complete")
-	return cleanup, nil
-}
-
-func setupUSDTEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	log := opts.Logger
-
-	log.Info("⌛ Setting up native USDT tracing environment...")
-
-	// Build the unified exporter image
-	log.Info("⌛ Building unified exporter image...")
-	exporterBuild := &BuildOpts{
-		Dir:     getRoot(),
-		Args:    map[string]string{},
-		Secrets: map[string]string{},
-	}
-	buildCmd := dockerClient.BuildCommand(ctx, exporterBuild, "bpftrace-exporter")
-	buildCmd.Args = append(buildCmd.Args, "-f", filepath.Join(getRoot(), "app/exporter/Dockerfile"))
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-	buildCmd.Env = os.Environ()
-	if err := buildCmd.Run(); err != nil {
-		log.Error("❌ Failed to build USDT exporter image", "error", err)
-		return nil, err
-	}
-	log.Info("✅ USDT exporter image built")
-
-	// Start the application container first
-	if err := dockerClient.ContainerStart(ctx, "usdt", container.StartOptions{}); err != nil {
-		log.Debug("❌ Failed to start USDT app container", "error", err)
-		return nil, err
-	}
-
-	// Wait for app to be ready
-	if err := waitForAppHealth(ctx, opts.Inputs.Port, "health"); err != nil {
-		log.Warn("⚠️ health check failed during USDT app startup", "error", err)
-	}
-
-	// Create bpftrace exporter container with unified exporter in native-usdt mode
-	log.Info("⌛ Creating USDT exporter container...")
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "bpftrace-exporter",
-		Env: []string{
-			"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
-			"TARGET_PID=1",
-			"BPFTRACE_SCRIPT=/app/native-usdt.bt",
-			"EXPORTER_MODE=native-usdt",
-		},
-	}, &container.HostConfig{
-		PidMode:    container.PidMode("container:usdt"),
-		Privileged: true,
-		Binds: []string{
-			"/proc:/host/proc",
-			"/sys:/sys:ro",
-		},
-	}, nil, nil, "go-usdt-native")
-	if err != nil {
-		log.Error("❌ Failed to create USDT exporter container", "error", err)
-		return nil, err
-	}
-
-	if err := dockerClient.NetworkConnect(ctx, networkName, "go-usdt-native", nil); err != nil {
-		log.Error("❌ Failed to connect go-usdt-native to network", "error", err)
-		return nil, err
-	}
-
-	// Start the exporter container
-	if err := dockerClient.ContainerStart(ctx, "go-usdt-native", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start USDT exporter", "error", err)
-		return nil, err
-	}
-
-	// Give exporter a moment to attach
-	time.Sleep(3 * time.Second)
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "go-usdt-native", opts)
-	}
-
-	log.Info("✅ Native USDT environment setup complete")
-	return cleanup, nil
-}
-
-func setupFlightRecorderEnvironment(ctx context.Context, opts *RunManyOpts) (func(container.StopOptions) error, error) {
-	log := opts.Logger
-
-	log.Info("⌛ Setting up FlightRecorder environment...")
-
-	// Build the exporter sidecar image
-	log.Info("⌛ Building FlightRecorder exporter image...")
-	exporterDir := filepath.Join(getRoot(), "app/flightrecorder/exporter")
-	exporterBuild := &BuildOpts{
-		Dir:        exporterDir,
-		ContextDir: exporterDir, // Use exporter dir as context to avoid picking up other files
-		Args:       map[string]string{},
-		Secrets:    map[string]string{},
-	}
-	buildCmd := dockerClient.BuildCommand(ctx, exporterBuild, "flightrecorder-exporter")
-	buildCmd.Stdout = os.Stdout
-	buildCmd.Stderr = os.Stderr
-	buildCmd.Env = os.Environ()
-	if err := buildCmd.Run(); err != nil {
-		log.Error("❌ Failed to build FlightRecorder exporter image", "error", err)
-		return nil, err
-	}
-	log.Info("✅ FlightRecorder exporter image built")
-
-	// Create shared volume for trace files
-	volumeName := "flightrecorder_traces"
-
-	// Remove existing exporter container if it exists
-	_ = dockerClient.ContainerRemove(ctx, "flightrecorder-exporter", container.RemoveOptions{Force: true})
-
-	// Create exporter container
-	log.Info("⌛ Creating FlightRecorder exporter container...")
-	_, err := dockerClient.ContainerCreate(ctx, &container.Config{
-		Image: "flightrecorder-exporter",
-		Env: []string{
-			"OTEL_EXPORTER_OTLP_ENDPOINT=otel-collector:4318",
-			"TRACE_OUTPUT_DIR=/tmp/traces",
-		},
-	}, &container.HostConfig{
-		Mounts: []mount.Mount{
-			{
-				Type:   mount.TypeVolume,
-				Source: volumeName,
-				Target: "/tmp/traces",
-			},
-		},
-	}, nil, nil, "flightrecorder-exporter")
-	if err != nil {
-		log.Error("❌ Failed to create FlightRecorder exporter container", "error", err)
-		return nil, err
-	}
-
-	// Connect exporter to network
-	if err := dockerClient.NetworkConnect(ctx, networkName, "flightrecorder-exporter", nil); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			log.Error("❌ Failed to connect flightrecorder-exporter to network", "error", err)
-			return nil, err
-		}
-	}
-
-	// Start the exporter container
-	if err := dockerClient.ContainerStart(ctx, "flightrecorder-exporter", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start FlightRecorder exporter", "error", err)
-		return nil, err
-	}
-
-	// Give exporter a moment to start watching
-	time.Sleep(2 * time.Second)
-
-	// Start the app container (was created in buildGoEnvironment but not started)
-	if err := dockerClient.ContainerStart(ctx, "flightrecorder", container.StartOptions{}); err != nil {
-		log.Error("❌ Failed to start flightrecorder app container", "error", err)
-		return nil, err
-	}
-
-	cleanup := func(opts container.StopOptions) error {
-		return dockerClient.ContainerStop(ctx, "flightrecorder-exporter", opts)
-	}
-
-	log.Info("✅ FlightRecorder environment setup complete")
 	return cleanup, nil
 }
 
@@ -918,11 +722,11 @@ func cleanup(log *slog.Logger) error {
 
 	// Make sure that all containers are stopped and removed, or else re-running
 	// will cause conflicts with existing container names.
-	for _, s := range allScenarios {
+	for _, s := range scenarioNames() {
 		_ = runSilently("docker", "rm", "-f", s)
 	}
 
-	for _, s := range containerNames {
+	for _, s := range containerNames() {
 		_ = runSilently("docker", "rm", "-f", s)
 	}
 
@@ -1011,6 +815,33 @@ func startStats(ctx context.Context, containerID string) func() ([]*container.St
 	}
 }
 
+// startAllStats starts collecting stats of the containers concurrently, see
+// startStats, and returns once all of them took their first snapshot.
+func startAllStats(ctx context.Context, containers []string) map[string]func() ([]*container.StatsResponse, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	stops := make(map[string]func() ([]*container.StatsResponse, error), len(containers))
+	for _, name := range containers {
+		wg.Go(func() {
+			stop := startStats(ctx, name)
+			mu.Lock()
+			stops[name] = stop
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return stops
+}
+
+// splitStats splits stats snapshots into those read before t and the rest.
+func splitStats(stats []*container.StatsResponse, t time.Time) ([]*container.StatsResponse, []*container.StatsResponse) {
+	i := 0
+	for i < len(stats) && stats[i].Read.Before(t) {
+		i++
+	}
+	return stats[:i], stats[i:]
+}
+
 func getContainerStats(ctx context.Context, containerID string) (container.StatsResponse, error) {
 	statsReader, err := dockerClient.ContainerStatsOneShot(ctx, containerID)
 	if err != nil {
@@ -2,13 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os/exec"
 	"path/filepath"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
+	"fosdem2026/app/schema"
+	"fosdem2026/cmd/stats"
+
 	docker "github.com/docker/docker/client"
 )
 
@@ -20,56 +23,120 @@ type RunManyOpts struct {
 	Force    bool
 	Num      int
 	Timeout  time.Duration
+	// ResultsDir is where results are cached, keyed by Input.Hash.
+	ResultsDir string
+	// HistogramsOnly drops individual requests from the results and keeps
+	// only their latency histograms.
+	HistogramsOnly bool
+	// Order is the order of the runs of the scenarios, see orderRuns.
+	Order Order
+	// Seed randomizes the order of the runs with OrderRandom.
+	Seed uint64
 }
 
 // TestResult holds timing and telemetry data from a single test run.
 type TestResult struct {
-	Start      time.Time                  `json:"start"`
-	AppStart   time.Time                  `json:"app_start"`
-	AppReady   time.Time                  `json:"app_ready"`
-	LoadStart  time.Time                  `json:"load_start"`
-	LoadEnd    time.Time                  `json:"load_end"`
-	StopStart  time.Time                  `json:"stop_start"`
-	StopEnd    time.Time                  `json:"stop_end"`
-	Requests   []Request                  `json:"requests"`
-	LoadStats  []*container.StatsResponse `json:"load_stats"`
-	StopStats  []*container.StatsResponse `json:"stop_stats"`
-	Profiles   []*ProfilePayload          `json:"profiles"`
-	Traces     []*TracesPayload           `json:"traces"`
-	Logs       []*LogPayload              `json:"logs"`
-	LoopsNum   int                        `json:"loops_num"`
-	AllocsNum  int                        `json:"allocs"`
-	RunnerOS   string                     `json:"runner_os,omitempty"`
-	RunnerArch string                     `json:"runner_arch,omitempty"`
-	RunnerCPU  int                        `json:"runner_cpu,omitempty"`
+	Scenario   string            `json:"scenario"`
+	Hash       string            `json:"hash"`
+	Run        int               `json:"run"`
+	Inputs     *Input            `json:"inputs"`
+	Cell       *Cell             `json:"cell,omitempty"`
+	Order      Order             `json:"order,omitempty"`
+	Seed       uint64            `json:"seed,omitempty"`
+	Position   int               `json:"position"`
+	Start      time.Time         `json:"start"`
+	AppStart   time.Time         `json:"app_start"`
+	AppReady   time.Time         `json:"app_ready"`
+	LoadStart  time.Time         `json:"load_start"`
+	LoadEnd    time.Time         `json:"load_end"`
+	StopStart  time.Time         `json:"stop_start"`
+	StopEnd    time.Time         `json:"stop_end"`
+	Requests   []Request         `json:"requests"`
+	Latency    *LatencyRecord    `json:"latency,omitempty"`
+	Stages     []*StageResult    `json:"stages,omitempty"`
+	Endpoints  []*EndpointResult `json:"endpoints,omitempty"`
+	MaxRPS     int               `json:"max_rps,omitempty"`
+	Probes     []*Probe          `json:"probes,omitempty"`
+	WarmUp     *WarmUpResult     `json:"warm_up,omitempty"`
+	LoadUsage  *stats.Stats      `json:"load_usage"`
+	StopUsage  *stats.Stats      `json:"stop_usage"`
+	Profiles   []*ProfilePayload `json:"profiles"`
+	Traces     []*TracesPayload  `json:"traces"`
+	Logs       []*LogPayload     `json:"logs"`
+	LoopsNum   int               `json:"loops_num"`
+	AllocsNum  int               `json:"allocs"`
+	RunnerOS   string            `json:"runner_os,omitempty"`
+	RunnerArch string            `json:"runner_arch,omitempty"`
+	RunnerCPU  int               `json:"runner_cpu,omitempty"`
+
+	// ContainerUsage holds the resource usage during load of the scenario's
+	// sidecars and the collector, by container name.
+	ContainerUsage map[string]*stats.Stats `json:"container_usage,omitempty"`
+	// TelemetryIncomplete is set for instrumented scenarios whose services
+	// exported fewer or more traces than requests were sent during load.
+	TelemetryIncomplete bool `json:"telemetry_incomplete,omitempty"`
+	// Fidelity is how accurately the spans describe the requests sent during
+	// load, for instrumented scenarios.
+	Fidelity *Fidelity `json:"fidelity,omitempty"`
 }
 
 // Request holds timing data for a single HTTP request.
 type Request struct {
-	End      time.Time     `json:"end"`
+	// Scheduled is the time at which the load generator intended to send the
+	// request.
+	Scheduled time.Time `json:"scheduled"`
+	// Endpoint is the name of the endpoint of the request mix the request
+	// was sent to.
+	Endpoint string    `json:"endpoint,omitempty"`
+	Status   int       `json:"status,omitempty"`
+	End      time.Time `json:"end"`
+	// Duration is the service time, measured from when the request was
+	// actually sent.
 	Duration time.Duration `json:"duration"`
-	Error    string        `json:"error"`
+	// ResponseTime is measured from Scheduled, so it includes any queueing
+	// delay in the load generator or the client.
+	ResponseTime time.Duration `json:"response_time"`
+	Error        string        `json:"error"`
 }
 
-// ProfilePayload holds profiling data collected during a test.
+// ProfilePayload holds a profile collected during a test, see
+// fetchProfiles.
 type ProfilePayload struct {
-	Error error    `json:"error"`
+	// Kind is the profile, e.g. "cpu" or "heap".
+	Kind string `json:"kind"`
+	// Error is why the profile could not be collected, if it couldn't.
+	Error string   `json:"error,omitempty"`
 	Files []string `json:"files"`
 	Bytes int64    `json:"bytes"`
 }
 
-// TracesPayload holds trace data collected during a test.
+// TracesPayload holds what a service exported to the collector during a
+// test.
 type TracesPayload struct {
-	Count int    `json:"count"`
-	Bytes int64  `json:"bytes"`
-	Trace []byte `json:"trace"`
+	Service string `json:"service"`
+	// Count is the number of spans and Bytes their size in protobuf.
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+	// Traces is the number of traces whose root span started during load,
+	// which should be one per request for instrumentation that traces them
+	// all.
+	Traces int `json:"traces"`
 }
 
-// LogPayload holds log data collected during a test.
+// LogPayload holds the logs of a container of a test, see captureLogs.
 type LogPayload struct {
-	Count int    `json:"count"`
-	Bytes int64  `json:"bytes"`
-	Logs  []byte `json:"logs"`
+	Container string `json:"container"`
+	// File holds the logs, with a timestamp per line.
+	File string `json:"file"`
+	// Count is the number of lines, of which Warnings and Errors look like
+	// warnings and errors.
+	Count    int   `json:"count"`
+	Bytes    int64 `json:"bytes"`
+	Warnings int   `json:"warnings"`
+	Errors   int   `json:"errors"`
+	// Error is why the logs could not be captured completely, if they
+	// couldn't.
+	Error string `json:"error,omitempty"`
 }
 
 // Client wraps the Docker client.
@@ -88,8 +155,12 @@ type BuildOpts struct {
 	Secrets map[string]string
 }
 
-// Input holds experiment configuration parameters.
+// Input holds experiment configuration parameters. The embedded schema.Input
+// is the part passed on to the application as inputs.json, the remaining
+// fields configure the runner.
 type Input struct {
+	schema.Input
+
 	// Hash of all inputs except for the hash itself.
 	Hash string `json:"hash"`
 
@@ -99,54 +170,28 @@ type Input struct {
 	// RuntimeVersion to test. For example, "1.24".
 	RuntimeVersion string `json:"runtime_version"`
 
-	// Workers is the number of workers to use. The exact meaning of this
-	// depends on the language. For example, in Go it's GOMAXPROCS. In node it's
-	// the number of cluster processes to spawn. The value of workers defaults
-	// to the number of logical CPUs on the machine.
-	Workers int `json:"workers"`
-
-	// LoopsCPU (in seconds) is the amount of CPU time to spend in a tight for
-	// loop in the request handler for each request. If set, the value of Loops
-	// will be ignored and overwritten with an auto-calibrated value.
-	LoopsCPU float64 `json:"loops_cpu"`
-
-	// LoopsNum is the number of iterations to perform in a tight loop.
-	LoopsNum int `json:"loops_num"`
-
-	// AllocsCPU (in seconds) is the amount of CPU time to spend in a for loop
-	// doing allocations of allocs_size bytes in the request handler for each
-	// request. The allocations are kept alive for the duration of the request.
-	// If set, the value of AllocsNum will be ignored and overwritten with an
-	// auto-calibrated value.
-	AllocsCPU float64 `json:"allocs_cpu"`
-
-	// AllocsNum is the number of allocations to perform in a tight loop.
-	AllocsNum int `json:"allocs_num"`
-
-	// AllocsSize is the size of the allocations to perform in a tight loop.
-	AllocsSize int `json:"allocs_size"`
-
-	// Exceptions controls whether to throw an exception or not in the request handler.
-	Exceptions bool `json:"exceptions"`
-
-	// OffCPU time (in seconds) to spend in the request handler for each request.
-	OffCPU float64 `json:"off_cpu"`
-
-	// Recursive refers to the Stack: should it be unique functions or a recursive function call.
-	Recursive bool `json:"recursive"`
-
 	// Flush controls whether the application is asked to gracefully shut down
 	// and flush traces and profiles. When disabled, the application is killed
 	// via SIGKILL and buffered traces and profiles are discarded.
 	Flush bool `json:"flush"`
 
-	// Port to listen on for the application.
-	Port int `json:"port"`
-
 	// RPS is the number of requests per second to generate in an open loop.
 	// This option is mutually exclusive with Concurrency.
 	RPS int `json:"rps"`
 
+	// Profile is a load profile (see ParseProfile) that replaces the constant
+	// RPS, e.g. "ramp:0-1000" or "step:100,500,1000". The profile spans
+	// Duration.
+	Profile string `json:"profile,omitempty"`
+
+	// FindMax, if set, replaces the load with a search for the highest RPS
+	// that meets the given thresholds, see findMax.
+	FindMax *FindMaxOpts `json:"find_max,omitempty"`
+
+	// Mix is the request mix to send, see Endpoint. If empty, all requests
+	// go to the load handler.
+	Mix []Endpoint `json:"mix,omitempty"`
+
 	// Clients is the number of goroutines that will generated requests in a
 	// closed loop. This option is mutually exclusive with RPS.
 	Clients int `json:"clients"`
@@ -157,8 +202,31 @@ type Input struct {
 	// Duration for which to put the application under load in seconds.
 	Duration float64 `json:"duration"`
 
-	// OtelEndpoint is the OpenTelemetry collector endpoint (e.g. "otel-collector:4318")
-	OtelEndpoint string `json:"otel_endpoint"`
+	// WarmUp is how long to send load before the measured load, in seconds.
+	// Its requests and stats are recorded separately.
+	WarmUp float64 `json:"warm_up,omitempty"`
+}
+
+// Validate checks the inputs of the application as well as those of the
+// runner.
+func (in *Input) Validate() error {
+	var errs []error
+	if err := in.Input.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if in.Clients > 0 && (in.RPS > 0 || in.Profile != "") {
+		errs = append(errs, fmt.Errorf("clients and rps cannot be set at the same time"))
+	}
+	if in.Duration <= 0 && in.FindMax == nil {
+		errs = append(errs, fmt.Errorf("duration must be positive"))
+	}
+	if in.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout must not be negative"))
+	}
+	if in.WarmUp < 0 {
+		errs = append(errs, fmt.Errorf("warm_up must not be negative"))
+	}
+	return errors.Join(errs...)
 }
 
 // NewClient creates a new Docker client.
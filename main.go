@@ -17,6 +17,9 @@ func main() {
 		Usage: "FOSDEM 2026 experiment runner",
 		Commands: []*cli.Command{
 			cmd.CmdRun,
+			cmd.CmdMatrix,
+			cmd.CmdReport,
+			cmd.CmdDiff,
 		},
 	}
 